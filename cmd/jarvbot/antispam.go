@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var spamLinkRegex = regexp.MustCompile(`(?i)https?://\S+`)
+
+type trackedMessage struct {
+	ID        string
+	ChannelID string
+	SentAt    time.Time
+	content   string
+	mentions  int
+	linkKeys  []string
+}
+
+// recent messages per guild and user, only kept for the guild's anti-spam window
+var spamTracker = map[string][]trackedMessage{}
+var spamTrackerMutex sync.Mutex
+
+var antiSpamSettingsCache = map[string]AntiSpamSettings{}
+var antiSpamSettingsCacheMutex sync.Mutex
+
+type antiSpamInput struct {
+	Status     string `long:"status" choice:"on" choice:"off" description:"Enables or disables the anti-spam"`
+	Window     string `short:"w" long:"window" description:"The sliding window where messages are counted, format: 99m99s, up to 5m"`
+	Messages   *int   `short:"m" long:"messages" description:"Max messages per user in the window, 0 disables the check"`
+	Duplicates *int   `short:"d" long:"duplicates" description:"Max messages with the same content in the window, in any channel. 0 disables the check"`
+	Mentions   *int   `long:"mentions" description:"Max mentions in the window, 0 disables the check"`
+	Links      *int   `short:"l" long:"links" description:"Max times the same link or attachment can be posted in the window, 0 disables the check"`
+	Action     string `short:"a" long:"action" choice:"log" choice:"delete" choice:"timeout" description:"What to do with spammers, delete and timeout also delete the spam"`
+	Timeout    string `short:"t" long:"timeout" description:"How long the timeout lasts, format: 99d99h99m"`
+	Reset      bool   `long:"reset" description:"Goes back to the default settings, disabled"`
+}
+
+// Command Answers
+
+// Format: !antispam [flags]
+// Without flags, it shows the current settings
+func answerAntiSpam(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var input antiSpamInput
+	if err := parseCommandArgs(&input, mc.Content); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+
+	settings, err := moddingDS.antiSpamSettings(mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerAntiSpam::antiSpamSettings", err, mc.GuildID, ds)
+		return false
+	}
+	changed := input.Reset || input.Status != "" || input.Window != "" || input.Messages != nil || input.Duplicates != nil ||
+		input.Mentions != nil || input.Links != nil || input.Action != "" || input.Timeout != ""
+	if input.Reset {
+		settings = defaultAntiSpamSettings(mc.GuildID)
+	}
+
+	if input.Status != "" {
+		settings.Enabled = input.Status == "on"
+	}
+	if input.Window != "" {
+		window := stringToDuration(input.Window)
+		if window <= 0 || window > antiSpamMaxWindow {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The window must be a duration like 10s, up to %s", humanDurationString(antiSpamMaxWindow)))
+			return false
+		}
+		settings.WindowSeconds = int(window.Seconds())
+	}
+	for _, limit := range []struct {
+		value *int
+		field *int
+	}{
+		{input.Messages, &settings.MaxMessages},
+		{input.Duplicates, &settings.MaxDuplicates},
+		{input.Mentions, &settings.MaxMentions},
+		{input.Links, &settings.MaxRepeatedLinks},
+	} {
+		if limit.value == nil {
+			continue
+		}
+		// going over the limit must fit in the tracked messages
+		if *limit.value < 0 || *limit.value >= antiSpamMaxTrackedMessages {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The limits must be between 0 and %d", antiSpamMaxTrackedMessages-1))
+			return false
+		}
+		*limit.field = *limit.value
+	}
+	if input.Action != "" {
+		settings.Action = input.Action
+	}
+	if input.Timeout != "" {
+		timeout := stringToDuration(input.Timeout)
+		if timeout <= 0 {
+			ds.ChannelMessageSend(mc.ChannelID, "The timeout must be a duration like 1h")
+			return false
+		}
+		settings.TimeoutSeconds = int(timeout.Seconds())
+	}
+
+	if changed {
+		err = moddingDS.setAntiSpamSettings(settings)
+		invalidateAntiSpamSettings(mc.GuildID)
+		if err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Could not save the anti-spam settings: "+err.Error())
+			return false
+		}
+	}
+
+	_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, antiSpamSettingsEmbed(settings))
+	return err == nil
+}
+
+func antiSpamSettingsEmbed(settings AntiSpamSettings) *discordgo.MessageEmbed {
+	limit := func(n int) string {
+		if n == 0 {
+			return "Disabled"
+		}
+		return fmt.Sprint(n)
+	}
+	status := "Disabled"
+	if settings.Enabled {
+		status = "Enabled"
+	}
+	action := settings.Action
+	if action == antiSpamActionTimeout {
+		action += " for " + humanDurationString(settings.Timeout())
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Anti-spam settings",
+		Color:       colorBlue,
+		Description: fmt.Sprintf("%s, counting messages in the last %s", status, humanDurationString(settings.Window())),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Messages", Value: limit(settings.MaxMessages), Inline: true},
+			{Name: "Duplicates", Value: limit(settings.MaxDuplicates), Inline: true},
+			{Name: "Mentions", Value: limit(settings.MaxMentions), Inline: true},
+			{Name: "Repeated links or files", Value: limit(settings.MaxRepeatedLinks), Inline: true},
+			{Name: "Action", Value: action, Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "Change them with !antispam [flags], see !antispam --help"},
+	}
+}
+
+// CRONs
+
+// users that stopped talking are never checked again, so their tracked messages are removed here
+func pruneSpamTrackerCRONFunc(ds *discordgo.Session) func() {
+	return func() {
+		spamTrackerMutex.Lock()
+		defer spamTrackerMutex.Unlock()
+		for key, messages := range spamTracker {
+			if len(messages) == 0 || time.Since(messages[len(messages)-1].SentAt) > antiSpamMaxWindow {
+				delete(spamTracker, key)
+			}
+		}
+	}
+}
+
+// Internal functions
+
+func defaultAntiSpamSettings(guildID string) AntiSpamSettings {
+	return AntiSpamSettings{
+		GuildID:          guildID,
+		WindowSeconds:    10,
+		MaxMessages:      8,
+		MaxDuplicates:    4,
+		MaxMentions:      10,
+		MaxRepeatedLinks: 4,
+		Action:           antiSpamActionTimeout,
+		TimeoutSeconds:   60 * 60,
+	}
+}
+
+func guildAntiSpamSettings(guildID string) (AntiSpamSettings, error) {
+	antiSpamSettingsCacheMutex.Lock()
+	defer antiSpamSettingsCacheMutex.Unlock()
+
+	if settings, ok := antiSpamSettingsCache[guildID]; ok {
+		return settings, nil
+	}
+	settings, err := moddingDS.antiSpamSettings(guildID)
+	if err != nil {
+		return settings, err
+	}
+	antiSpamSettingsCache[guildID] = settings
+	return settings, nil
+}
+
+func invalidateAntiSpamSettings(guildID string) {
+	antiSpamSettingsCacheMutex.Lock()
+	defer antiSpamSettingsCacheMutex.Unlock()
+	delete(antiSpamSettingsCache, guildID)
+}
+
+// checkSpam tracks the message and punishes its author if they are spamming
+// Returns true if the spam was deleted, so it should not be processed any further
+func checkSpam(ds *discordgo.Session, m *discordgo.Message) bool {
+	if m.GuildID == "" || m.Author == nil {
+		return false
+	}
+	settings, err := guildAntiSpamSettings(m.GuildID)
+	if err != nil {
+		serverNotifyIfErr("checkSpam::guildAntiSpamSettings", err, m.GuildID, ds)
+		return false
+	}
+	if !settings.Enabled {
+		return false
+	}
+
+	reason, spam := trackMessage(settings, m)
+	// mods are only checked after detecting spam, since it's the expensive part
+	if reason == "" || isMod(ds, m.Author.ID, m.ChannelID) {
+		return false
+	}
+
+	deleted := 0
+	if settings.Action != antiSpamActionLog {
+		deleted = deleteSpam(ds, m.GuildID, spam)
+	}
+
+	sanction := ""
+	if settings.Action == antiSpamActionTimeout {
+		timeoutRoleID, err := resolveTimeoutRoleID(ds, m.GuildID)
+		var caseNumber int
+		if err == nil {
+			caseNumber, err = sendToShadowRealm(ds, m.GuildID, m.Author.ID, timeoutRoleID, settings.Timeout(), ds.State.User.ID, caseSourceAntiSpam, reason)
+		}
+		serverNotifyIfErr("checkSpam::sendToShadowRealm", err, m.GuildID, ds)
+		if err == nil {
+			sanction = fmt.Sprintf("\nTimed out for %s (case #%d)", humanDurationString(settings.Timeout()), caseNumber)
+		}
+	}
+
+	lastMessage := redactLogContent(m.GuildID, m.Content)
+	if lastMessage == "" {
+		lastMessage = "(no text)"
+	}
+	sendModLog(ds, m.GuildID, &discordgo.MessageEmbed{
+		Author:      userEmbedAuthor(m.Author),
+		Title:       "Spam detected",
+		Color:       colorRed,
+		Description: fmt.Sprintf("User: <@%s>\nChannel: <#%s>\nReason: %s\nDeleted messages: %d/%d%s", m.Author.ID, m.ChannelID, reason, deleted, len(spam), sanction),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Last message", Value: truncateString(lastMessage, embedFieldValueMaxLength-1)},
+		},
+	})
+	return settings.Action != antiSpamActionLog
+}
+
+// trackMessage adds the message to the author's recent messages and checks them against the limits
+// When spam is detected, returns the reason and the messages in the window, which stop being tracked
+func trackMessage(settings AntiSpamSettings, m *discordgo.Message) (string, []trackedMessage) {
+	tracked := trackedMessage{
+		ID:        m.ID,
+		ChannelID: m.ChannelID,
+		SentAt:    time.Now(),
+		content:   strings.ToLower(strings.Join(strings.Fields(m.Content), " ")),
+		mentions:  len(m.Mentions) + len(m.MentionRoles),
+	}
+	if m.MentionEveryone {
+		tracked.mentions++
+	}
+	for _, link := range spamLinkRegex.FindAllString(m.Content, -1) {
+		tracked.linkKeys = append(tracked.linkKeys, strings.ToLower(link))
+	}
+	for _, a := range m.Attachments {
+		tracked.linkKeys = append(tracked.linkKeys, fmt.Sprintf("file:%s:%d", a.Filename, a.Size))
+	}
+
+	key := m.GuildID + ";" + m.Author.ID
+	spamTrackerMutex.Lock()
+	defer spamTrackerMutex.Unlock()
+
+	messages := append(spamTracker[key], tracked)
+	windowStart := time.Now().Add(-settings.Window())
+	for len(messages) > 0 && (messages[0].SentAt.Before(windowStart) || len(messages) > antiSpamMaxTrackedMessages) {
+		messages = messages[1:]
+	}
+
+	reason := spamReason(settings, messages)
+	if reason == "" {
+		spamTracker[key] = messages
+		return "", nil
+	}
+	delete(spamTracker, key)
+	return reason, messages
+}
+
+func spamReason(settings AntiSpamSettings, messages []trackedMessage) string {
+	mentions := 0
+	contents := map[string]int{}
+	links := map[string]int{}
+	for _, m := range messages {
+		mentions += m.mentions
+		if m.content != "" {
+			contents[m.content]++
+		}
+		for _, link := range m.linkKeys {
+			links[link]++
+		}
+	}
+
+	window := humanDurationString(settings.Window())
+	if settings.MaxMentions > 0 && mentions > settings.MaxMentions {
+		return fmt.Sprintf("%d mentions in %s", mentions, window)
+	}
+	if settings.MaxDuplicates > 0 {
+		for _, count := range contents {
+			if count > settings.MaxDuplicates {
+				return fmt.Sprintf("%d duplicated messages in %s", count, window)
+			}
+		}
+	}
+	if settings.MaxRepeatedLinks > 0 {
+		for _, count := range links {
+			if count > settings.MaxRepeatedLinks {
+				return fmt.Sprintf("the same link or file %d times in %s", count, window)
+			}
+		}
+	}
+	if settings.MaxMessages > 0 && len(messages) > settings.MaxMessages {
+		return fmt.Sprintf("%d messages in %s", len(messages), window)
+	}
+	return ""
+}
+
+// deleteSpam bulk deletes the messages of each channel, returns how many were deleted
+func deleteSpam(ds *discordgo.Session, guildID string, spam []trackedMessage) int {
+	byChannel := map[string][]string{}
+	for _, m := range spam {
+		byChannel[m.ChannelID] = append(byChannel[m.ChannelID], m.ID)
+	}
+
+	deleted := 0
+	for channelID, messageIDs := range byChannel {
+		var err error
+		if len(messageIDs) == 1 {
+			err = ds.ChannelMessageDelete(channelID, messageIDs[0])
+		} else {
+			err = ds.ChannelMessagesBulkDelete(channelID, messageIDs)
+		}
+		serverNotifyIfErr("deleteSpam", err, guildID, ds)
+		if err == nil {
+			deleted += len(messageIDs)
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var attachmentDownloadClient = &http.Client{Timeout: attachmentDownloadTimeout}
+
+// guards the reserved space and the pending archives, the downloads run without it
+var attachmentArchiveMutex sync.Mutex
+
+// bytes being downloaded per guild, the empty key holds the total, so concurrent downloads can't exceed the quotas
+var attachmentArchiveReserved = map[string]int64{}
+
+// messages whose attachments are still being archived, by message ID
+var pendingAttachmentArchives = map[string]*pendingAttachmentArchive{}
+
+// pendingAttachmentArchive remembers a deletion that happened during the archiving, to handle it once it's done
+type pendingAttachmentArchive struct {
+	deleted       bool
+	logsChannelID string
+}
+
+// Command Answers
+
+// Format: !attachmentarchive on [quota in MB] | off
+func answerAttachmentArchive(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) == 0 {
+		status := "disabled"
+		if isAttachmentArchiveEnabled(mc.GuildID) {
+			used, _ := moddingDS.archivedAttachmentsSize(mc.GuildID)
+			status = fmt.Sprintf("enabled, using %.1f of %d MB", float64(used)/1024/1024, attachmentArchiveQuotaMB(mc.GuildID))
+		}
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The attachment archive is %s\nFormat: !attachmentarchive on [quota in MB] | off", status))
+		return true
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		quota := attachmentArchiveDefaultQuotaMB
+		if len(args) > 1 {
+			var err error
+			quota, err = strconv.Atoi(args[1])
+			if err != nil || quota <= 0 || quota > attachmentArchiveMaxQuotaMB {
+				ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The quota must be a number of MB between 1 and %d", attachmentArchiveMaxQuotaMB))
+				return false
+			}
+		}
+		err := serverDS.setServerProperty(mc.GuildID, serverPropAttachmentArchiveQuota, strconv.Itoa(quota))
+		if err == nil {
+			err = serverDS.setServerProperty(mc.GuildID, serverPropAttachmentArchive, serverPropYes)
+		}
+		serverNotifyIfErr("answerAttachmentArchive::on", err, mc.GuildID, ds)
+		if err == nil {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Okay! Will keep up to %d MB of attachments from logged channels, so they can be reuploaded when deleted", quota))
+		}
+		return err == nil
+	case "off":
+		err := serverDS.setServerProperty(mc.GuildID, serverPropAttachmentArchive, serverPropNo)
+		if err == nil {
+			err = purgeArchivedAttachments(mc.GuildID, time.Now())
+		}
+		serverNotifyIfErr("answerAttachmentArchive::off", err, mc.GuildID, ds)
+		if err == nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Okay! Will not archive attachments anymore, the archived ones have been deleted")
+		}
+		return err == nil
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, "Format: !attachmentarchive on [quota in MB] | off")
+	return false
+}
+
+// CRONs
+
+// archived attachments are only useful while the deleted message can still be found in the state or the message cache
+func purgeAttachmentArchiveCRONFunc(ds *discordgo.Session) func() {
+	return func() {
+		guildIDs, err := moddingDS.archivedAttachmentGuildIDs()
+		if err != nil {
+			adminNotifyIfErr("purgeAttachmentArchiveCRONFunc", err, ds)
+			return
+		}
+		for _, guildID := range guildIDs {
+			before := time.Now()
+			if isAttachmentArchiveEnabled(guildID) {
+				before = before.Add(-attachmentArchiveRetention(guildID))
+			}
+			err = purgeArchivedAttachments(guildID, before)
+			adminNotifyIfErr("purgeAttachmentArchiveCRONFunc::purgeArchivedAttachments", err, ds)
+		}
+	}
+}
+
+// Internal functions
+
+func isAttachmentArchiveEnabled(guildID string) bool {
+	enabled, _ := serverDS.getServerProperty(guildID, serverPropAttachmentArchive)
+	return enabled == serverPropYes
+}
+
+func attachmentArchiveQuotaMB(guildID string) int {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropAttachmentArchiveQuota)
+	quota, err := strconv.Atoi(raw)
+	if err != nil || quota <= 0 {
+		return attachmentArchiveDefaultQuotaMB
+	}
+	return min(quota, attachmentArchiveMaxQuotaMB)
+}
+
+func attachmentArchiveRetention(guildID string) time.Duration {
+	if isMessageCacheEnabled(guildID) {
+		return messageCacheRetention(guildID)
+	}
+	return stateMessageMaxLifetime
+}
+
+// archiveMessageAttachments downloads the attachments of a message sent in a logged channel in the background
+// Files too big to be reuploaded are skipped, old files are evicted to respect the guild quota and the global cap
+// The message is marked as pending before, so a deletion that arrives during the download is not missed
+func archiveMessageAttachments(ds *discordgo.Session, m *discordgo.Message) {
+	if m == nil || m.GuildID == "" || len(m.Attachments) == 0 {
+		return
+	}
+	attachmentArchiveMutex.Lock()
+	pendingAttachmentArchives[m.ID] = &pendingAttachmentArchive{}
+	attachmentArchiveMutex.Unlock()
+
+	go func() {
+		defer finishAttachmentArchive(ds, m)
+		if !isAttachmentArchiveEnabled(m.GuildID) || logChannelID(m.GuildID, logCategoryMessages) == "" || isMessageLogIgnored(ds, m) {
+			return
+		}
+		for _, a := range m.Attachments {
+			if a.Size <= 0 || a.Size > discordMaxUploadSize {
+				continue
+			}
+			err := archiveAttachment(m, a)
+			serverNotifyIfErr("archiveMessageAttachments", err, m.GuildID, ds)
+		}
+	}()
+}
+
+// finishAttachmentArchive reuploads and discards the archived attachments if the message was deleted while archiving them
+func finishAttachmentArchive(ds *discordgo.Session, m *discordgo.Message) {
+	attachmentArchiveMutex.Lock()
+	pending := pendingAttachmentArchives[m.ID]
+	delete(pendingAttachmentArchives, m.ID)
+	attachmentArchiveMutex.Unlock()
+
+	if pending == nil || (!pending.deleted && pending.logsChannelID == "") {
+		return
+	}
+	if pending.logsChannelID != "" {
+		sendArchivedAttachments(ds, m.GuildID, pending.logsChannelID, m.ID)
+	}
+	discardArchivedAttachments(ds, m.GuildID, m.ID)
+}
+
+// markPendingAttachmentArchive records a deletion of a message that is still being archived, returns false if it's not
+func markPendingAttachmentArchive(messageID, logsChannelID string) bool {
+	attachmentArchiveMutex.Lock()
+	defer attachmentArchiveMutex.Unlock()
+	pending, ok := pendingAttachmentArchives[messageID]
+	if !ok {
+		return false
+	}
+	pending.deleted = true
+	if logsChannelID != "" {
+		pending.logsChannelID = logsChannelID
+	}
+	return true
+}
+
+func archiveAttachment(m *discordgo.Message, a *discordgo.MessageAttachment) error {
+	size := int64(a.Size)
+	if err := reserveAttachmentArchiveSpace(m.GuildID, size); err != nil {
+		return err
+	}
+	// released after the row is added, so the size is always counted once
+	defer releaseAttachmentArchiveSpace(m.GuildID, size)
+
+	dir := filepath.Join(attachmentArchiveDir, m.GuildID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	// the original filename is only stored in the DB, so it can't be used to escape the archive
+	path := filepath.Join(dir, m.ID+"_"+a.ID)
+	written, err := downloadToFile(a.URL, path, size)
+	if err != nil {
+		return err
+	}
+
+	err = moddingDS.addArchivedAttachment(ArchivedAttachment{
+		MessageID:    m.ID,
+		AttachmentID: a.ID,
+		GuildID:      m.GuildID,
+		ChannelID:    m.ChannelID,
+		Filename:     a.Filename,
+		ContentType:  a.ContentType,
+		Path:         path,
+		Size:         written,
+	})
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// reserveAttachmentArchiveSpace evicts old attachments until the new one fits with the ones being downloaded, then reserves its size
+func reserveAttachmentArchiveSpace(guildID string, size int64) error {
+	attachmentArchiveMutex.Lock()
+	defer attachmentArchiveMutex.Unlock()
+
+	guildQuota := int64(attachmentArchiveQuotaMB(guildID)) * 1024 * 1024
+	if err := evictArchivedAttachments(guildID, guildQuota-attachmentArchiveReserved[guildID]-size); err != nil {
+		return err
+	}
+	totalQuota := int64(attachmentArchiveMaxTotalMB) * 1024 * 1024
+	if err := evictArchivedAttachments("", totalQuota-attachmentArchiveReserved[""]-size); err != nil {
+		return err
+	}
+	attachmentArchiveReserved[guildID] += size
+	attachmentArchiveReserved[""] += size
+	return nil
+}
+
+func releaseAttachmentArchiveSpace(guildID string, size int64) {
+	attachmentArchiveMutex.Lock()
+	defer attachmentArchiveMutex.Unlock()
+	for _, key := range []string{guildID, ""} {
+		if attachmentArchiveReserved[key] -= size; attachmentArchiveReserved[key] <= 0 {
+			delete(attachmentArchiveReserved, key)
+		}
+	}
+}
+
+// evictArchivedAttachments removes the oldest attachments of the guild (or of every guild if guildID is empty)
+// until their total size is at most maxSize
+func evictArchivedAttachments(guildID string, maxSize int64) error {
+	for {
+		size, err := moddingDS.archivedAttachmentsSize(guildID)
+		if err != nil {
+			return err
+		}
+		if size <= max(maxSize, 0) {
+			return nil
+		}
+		oldest, err := moddingDS.oldestArchivedAttachment(guildID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = removeArchivedAttachmentFile(oldest); err != nil {
+			return err
+		}
+	}
+}
+
+func downloadToFile(url, path string, maxSize int64) (int64, error) {
+	resp, err := attachmentDownloadClient.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("could not download the attachment, status %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, io.LimitReader(resp.Body, maxSize))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return written, err
+}
+
+// sendArchivedAttachments reuploads the archived attachments of a deleted message to the logs channel
+func sendArchivedAttachments(ds *discordgo.Session, guildID, logsChannelID, messageID string) {
+	if markPendingAttachmentArchive(messageID, logsChannelID) {
+		return
+	}
+	attachments, err := moddingDS.messageArchivedAttachments(messageID)
+	if err != nil || len(attachments) == 0 {
+		serverNotifyIfErr("sendArchivedAttachments", err, guildID, ds)
+		return
+	}
+
+	var files []*discordgo.File
+	var batchSize int64
+	send := func() {
+		if len(files) == 0 {
+			return
+		}
+		_, err := ds.ChannelMessageSendComplex(logsChannelID, &discordgo.MessageSend{
+			Content: fmt.Sprintf("Attachments of the deleted message %s", messageID),
+			Files:   files,
+		})
+		serverNotifyIfErr("sendArchivedAttachments::ChannelMessageSendComplex", err, guildID, ds)
+		for _, f := range files {
+			f.Reader.(*os.File).Close()
+		}
+		files, batchSize = nil, 0
+	}
+
+	for _, a := range attachments {
+		file, err := os.Open(a.Path)
+		if err != nil {
+			log.Println("sendArchivedAttachments:", err)
+			continue
+		}
+		if len(files) == discordMaxFilesPerMessage || batchSize+a.Size > discordMaxUploadSize {
+			send()
+		}
+		files = append(files, &discordgo.File{Name: a.Filename, ContentType: a.ContentType, Reader: file})
+		batchSize += a.Size
+	}
+	send()
+}
+
+// discardArchivedAttachments deletes the archived attachments of a message, once they are no longer needed
+func discardArchivedAttachments(ds *discordgo.Session, guildID, messageID string) {
+	if markPendingAttachmentArchive(messageID, "") {
+		return
+	}
+	attachments, err := moddingDS.messageArchivedAttachments(messageID)
+	if err != nil {
+		serverNotifyIfErr("discardArchivedAttachments", err, guildID, ds)
+		return
+	}
+	for _, a := range attachments {
+		err = removeArchivedAttachmentFile(a)
+		serverNotifyIfErr("discardArchivedAttachments::removeArchivedAttachmentFile", err, guildID, ds)
+	}
+}
+
+// purgeArchivedAttachments deletes the guild's attachments archived before the given time
+func purgeArchivedAttachments(guildID string, before time.Time) error {
+	attachments, err := moddingDS.archivedAttachmentsBefore(guildID, before)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if err = removeArchivedAttachmentFile(a); err != nil {
+			return err
+		}
+	}
+	if len(attachments) > 0 {
+		log.Printf("Purged %d archived attachments from guild %s", len(attachments), guildID)
+	}
+	return nil
+}
+
+func removeArchivedAttachmentFile(a ArchivedAttachment) error {
+	if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return moddingDS.removeArchivedAttachment(a.ID)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var automodInviteRegex = regexp.MustCompile(`(?i)(?:discord(?:app)?\.com/invite|discord\.(?:gg|io|me|li))/[\w-]+`)
+var automodLinkHostRegex = regexp.MustCompile(`(?i)\bhttps?://([^\s/?#<>]+)`)
+var automodCustomEmojiRegex = regexp.MustCompile(`<a?:\w+:\d+>`)
+
+// compiled rules per guild, so messages don't hit the DB. Invalidated when the rules change
+var automodRulesCache = map[string][]automodCompiledRule{}
+var automodRulesCacheMutex sync.Mutex
+
+type automodCompiledRule struct {
+	AutomodRule
+	regex            *regexp.Regexp
+	domains          []string
+	exemptChannelIDs []string
+	exemptRoleIDs    []string
+}
+
+// Slash Command answers
+
+func answerAutomod(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	subcommand := ic.ApplicationCommandData().Options[0]
+	options := optionMap(subcommand.Options)
+
+	switch subcommand.Name {
+	case "add":
+		answerAutomodAdd(ds, ic, options)
+	case "remove":
+		ruleID := optionIntValueOrZero(options["id"])
+		err := moddingDS.removeAutomodRule(ruleID, ic.GuildID)
+		invalidateAutomodRules(ic.GuildID)
+		switch err {
+		case nil:
+			textRespond(ds, ic, commandSuccessMessage)
+		case errZeroRowsAffected:
+			textRespond(ds, ic, fmt.Sprintf("Could not find the automod rule #%d u_u", ruleID))
+		default:
+			textRespond(ds, ic, "Couldn't remove the rule: "+err.Error())
+		}
+	case "list":
+		rules, err := guildAutomodRules(ic.GuildID)
+		if err != nil {
+			textRespond(ds, ic, "Couldn't get the rules: "+err.Error())
+			return
+		}
+		if len(rules) == 0 {
+			textRespond(ds, ic, "No automod rules, wanna add some? :3 (/automod add)")
+			return
+		}
+		var b strings.Builder
+		for _, r := range rules {
+			b.WriteString(r.String() + "\n")
+		}
+		textRespond(ds, ic, truncateString(b.String(), discordMessageMaxLength))
+	case "test":
+		answerAutomodTest(ds, ic, options["text"].StringValue())
+	}
+}
+
+func answerAutomodAdd(ds *discordgo.Session, ic *discordgo.InteractionCreate, options map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	rule := AutomodRule{
+		GuildID:     ic.GuildID,
+		MatchType:   options["match"].StringValue(),
+		Action:      options["action"].StringValue(),
+		Threshold:   optionIntValueOrZero(options["threshold"]),
+		CreatedByID: interactionUser(ic).ID,
+	}
+	if opt, ok := options["pattern"]; ok {
+		rule.Pattern = strings.TrimSpace(opt.StringValue())
+	}
+	if opt, ok := options["exempt_channels"]; ok {
+		rule.ExemptChannelIDs = strings.Join(extractDiscordIDs(opt.StringValue()), serverPropListSeparator)
+	}
+	if opt, ok := options["exempt_roles"]; ok {
+		rule.ExemptRoleIDs = strings.Join(extractDiscordIDs(opt.StringValue()), serverPropListSeparator)
+	}
+	if rule.Action == automodActionTimeout {
+		duration := automodDefaultTimeout
+		if opt, ok := options["duration"]; ok {
+			duration = stringToDuration(opt.StringValue())
+		}
+		if duration <= 0 {
+			textRespond(ds, ic, "Invalid duration, use something like 10m or 1h")
+			return
+		}
+		rule.DurationSeconds = int(duration.Seconds())
+	}
+
+	if _, err := compileAutomodRule(rule); err != nil {
+		textRespond(ds, ic, "Invalid rule, "+err.Error())
+		return
+	}
+
+	current, err := guildAutomodRules(ic.GuildID)
+	if err != nil {
+		textRespond(ds, ic, "Couldn't get the rules: "+err.Error())
+		return
+	}
+	if len(current) >= automodMaxRulesPerGuild {
+		textRespond(ds, ic, "Too many automod rules!, please clean up before adding more :3")
+		return
+	}
+
+	rule.ID, err = moddingDS.addAutomodRule(rule)
+	invalidateAutomodRules(ic.GuildID)
+	if err != nil {
+		textRespond(ds, ic, "Couldn't add the rule: "+err.Error())
+		return
+	}
+	textRespond(ds, ic, "Added the rule "+rule.String())
+}
+
+func answerAutomodTest(ds *discordgo.Session, ic *discordgo.InteractionCreate, text string) {
+	rules, err := guildAutomodRules(ic.GuildID)
+	if err != nil {
+		ephemeralRespond(ds, ic, "Couldn't get the rules: "+err.Error())
+		return
+	}
+
+	var b strings.Builder
+	for _, r := range rules {
+		if matched := r.match(text); matched != "" {
+			b.WriteString(fmt.Sprintf("Rule #%d would %s (matched `%s`)\n", r.ID, r.Action, matched))
+		}
+	}
+	if b.Len() == 0 {
+		ephemeralRespond(ds, ic, "No rule would be triggered by that text :D")
+		return
+	}
+	b.WriteString("Only the most severe action is applied, and channel or role exemptions are not checked in tests")
+	ephemeralRespond(ds, ic, truncateString(b.String(), discordMessageMaxLength))
+}
+
+// Internal functions
+
+func (r AutomodRule) String() string {
+	str := fmt.Sprintf("**#%d** %s", r.ID, r.MatchType)
+	if r.Pattern != "" {
+		str += fmt.Sprintf(" `%s`", truncateString(r.Pattern, 100))
+	}
+	if r.Threshold > 0 {
+		str += fmt.Sprintf(" (threshold %d)", r.Threshold)
+	}
+	str += " → " + r.Action
+	if r.DurationSeconds > 0 {
+		str += " for " + humanDurationString(r.Duration())
+	}
+	for _, id := range splitNonEmpty(r.ExemptChannelIDs, serverPropListSeparator) {
+		str += fmt.Sprintf(" -<#%s>", id)
+	}
+	for _, id := range splitNonEmpty(r.ExemptRoleIDs, serverPropListSeparator) {
+		str += fmt.Sprintf(" -<@&%s>", id)
+	}
+	return str
+}
+
+func compileAutomodRule(r AutomodRule) (automodCompiledRule, error) {
+	compiled := automodCompiledRule{
+		AutomodRule:      r,
+		exemptChannelIDs: splitNonEmpty(r.ExemptChannelIDs, serverPropListSeparator),
+		exemptRoleIDs:    splitNonEmpty(r.ExemptRoleIDs, serverPropListSeparator),
+	}
+	if !slices.Contains(automodActions, r.Action) {
+		return compiled, fmt.Errorf("unknown automod action: %s", r.Action)
+	}
+
+	switch r.MatchType {
+	case automodMatchWords:
+		var words []string
+		for _, w := range strings.Split(r.Pattern, ",") {
+			if w = strings.TrimSpace(w); w != "" {
+				words = append(words, regexp.QuoteMeta(w))
+			}
+		}
+		if len(words) == 0 {
+			return compiled, errors.New("the words rule needs a pattern, like: word1, word2")
+		}
+		// \b would not work with words that start or end with symbols
+		compiled.regex = regexp.MustCompile(`(?i)(?:^|[^\pL\pN_])(` + strings.Join(words, "|") + `)(?:$|[^\pL\pN_])`)
+	case automodMatchRegex:
+		if r.Pattern == "" {
+			return compiled, errors.New("the regex rule needs a regex as the pattern")
+		}
+		regex, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return compiled, fmt.Errorf("the pattern is not a valid regex: %w", err)
+		}
+		compiled.regex = regex
+	case automodMatchDomains:
+		for _, d := range strings.Split(r.Pattern, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			d = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(d, "https://"), "http://"), "www.")
+			if d = strings.TrimSuffix(d, "/"); d != "" {
+				compiled.domains = append(compiled.domains, d)
+			}
+		}
+		if len(compiled.domains) == 0 {
+			return compiled, errors.New("the domains rule needs a pattern, like: example.com, example.org")
+		}
+	case automodMatchInvites:
+	case automodMatchCaps:
+		if compiled.Threshold <= 0 || compiled.Threshold > 100 {
+			compiled.Threshold = automodDefaultCapsPercent
+		}
+	case automodMatchEmoji:
+		if compiled.Threshold <= 0 {
+			compiled.Threshold = automodDefaultEmojiCount
+		}
+	default:
+		return compiled, fmt.Errorf("unknown automod match type: %s", r.MatchType)
+	}
+	return compiled, nil
+}
+
+// match returns the offending part of the content, or an empty string if the rule does not match
+func (r automodCompiledRule) match(content string) string {
+	switch r.MatchType {
+	case automodMatchWords:
+		if match := r.regex.FindStringSubmatch(content); match != nil {
+			return match[1]
+		}
+	case automodMatchRegex:
+		return r.regex.FindString(content)
+	case automodMatchInvites:
+		return automodInviteRegex.FindString(content)
+	case automodMatchDomains:
+		for _, match := range automodLinkHostRegex.FindAllStringSubmatch(content, -1) {
+			host := strings.ToLower(match[1])
+			if i := strings.LastIndex(host, "@"); i >= 0 {
+				host = host[i+1:]
+			}
+			if i := strings.Index(host, ":"); i >= 0 {
+				host = host[:i]
+			}
+			for _, d := range r.domains {
+				if host == d || strings.HasSuffix(host, "."+d) {
+					return host
+				}
+			}
+		}
+	case automodMatchCaps:
+		letters, upper := 0, 0
+		for _, c := range content {
+			if unicode.IsLetter(c) {
+				letters++
+				if unicode.IsUpper(c) {
+					upper++
+				}
+			}
+		}
+		if letters >= automodCapsMinLetters && upper*100 >= r.Threshold*letters {
+			return fmt.Sprintf("%d%% caps", upper*100/letters)
+		}
+	case automodMatchEmoji:
+		if count := countEmoji(content); count >= r.Threshold {
+			return fmt.Sprintf("%d emoji", count)
+		}
+	}
+	return ""
+}
+
+func (r automodCompiledRule) isExempt(ds *discordgo.Session, m *discordgo.Message) bool {
+	for _, channelID := range channelAndParentIDs(ds, m.ChannelID) {
+		if slices.Contains(r.exemptChannelIDs, channelID) {
+			return true
+		}
+	}
+	if m.Member != nil {
+		for _, roleID := range m.Member.Roles {
+			if slices.Contains(r.exemptRoleIDs, roleID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func guildAutomodRules(guildID string) ([]automodCompiledRule, error) {
+	automodRulesCacheMutex.Lock()
+	defer automodRulesCacheMutex.Unlock()
+
+	if rules, ok := automodRulesCache[guildID]; ok {
+		return rules, nil
+	}
+	stored, err := moddingDS.automodRules(guildID)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]automodCompiledRule, 0, len(stored))
+	for _, r := range stored {
+		// rules are validated when added, so this should not happen
+		if compiled, err := compileAutomodRule(r); err == nil {
+			rules = append(rules, compiled)
+		}
+	}
+	automodRulesCache[guildID] = rules
+	return rules, nil
+}
+
+func invalidateAutomodRules(guildID string) {
+	automodRulesCacheMutex.Lock()
+	defer automodRulesCacheMutex.Unlock()
+	delete(automodRulesCache, guildID)
+}
+
+// applyAutomod checks the message against the guild's rules and applies the most severe matching one
+// Returns true if the message was deleted, so it should not be processed any further
+func applyAutomod(ds *discordgo.Session, m *discordgo.Message) bool {
+	if m.GuildID == "" || m.Author == nil {
+		return false
+	}
+	rules, err := guildAutomodRules(m.GuildID)
+	if err != nil {
+		serverNotifyIfErr("applyAutomod::guildAutomodRules", err, m.GuildID, ds)
+		return false
+	}
+
+	var triggered *automodCompiledRule
+	var matched string
+	for i, r := range rules {
+		if triggered != nil && slices.Index(automodActions, r.Action) <= slices.Index(automodActions, triggered.Action) {
+			continue
+		}
+		if match := r.match(m.Content); match != "" && !r.isExempt(ds, m) {
+			triggered, matched = &rules[i], match
+		}
+	}
+	// mods are only checked after a match, since it's the expensive part
+	if triggered == nil || isMod(ds, m.Author.ID, m.ChannelID) {
+		return false
+	}
+
+	reason := fmt.Sprintf("Automod rule #%d (%s)", triggered.ID, triggered.MatchType)
+	deleted := false
+	if triggered.Action != automodActionLog {
+		err = ds.ChannelMessageDelete(m.ChannelID, m.ID)
+		serverNotifyIfErr("applyAutomod::ChannelMessageDelete", err, m.GuildID, ds)
+		deleted = err == nil
+	}
+
+	sanction := ""
+	switch triggered.Action {
+	case automodActionWarn:
+		sanction = automodWarn(ds, m, reason)
+	case automodActionTimeout:
+		timeoutRoleID, err := resolveTimeoutRoleID(ds, m.GuildID)
+		var caseNumber int
+		if err == nil {
+			caseNumber, err = sendToShadowRealm(ds, m.GuildID, m.Author.ID, timeoutRoleID, triggered.Duration(), ds.State.User.ID, caseSourceAutomod, reason)
+		}
+		if err == nil {
+			sanction = fmt.Sprintf("\nCase: #%d", caseNumber)
+		}
+		serverNotifyIfErr("applyAutomod::sendToShadowRealm", err, m.GuildID, ds)
+	}
+
+	sendModLog(ds, m.GuildID, &discordgo.MessageEmbed{
+		Author:      userEmbedAuthor(m.Author),
+		Title:       fmt.Sprintf("Automod: %s", triggered.Action),
+		Color:       colorRed,
+		Description: fmt.Sprintf("User: <@%s>\nChannel: <#%s>\nRule: %s\nMatched: `%s`%s", m.Author.ID, m.ChannelID, triggered.String(), matched, sanction),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Message", Value: truncateString(redactLogContent(m.GuildID, m.Content), embedFieldValueMaxLength-1)},
+		},
+	})
+	return deleted
+}
+
+// automodWarn warns the author and applies the warning policies, returns the text for the mod log
+func automodWarn(ds *discordgo.Session, m *discordgo.Message, reason string) string {
+	warningID, err := moddingDS.warnUser(m.Author.ID, ds.State.User.ID, m.GuildID, reason, int(warnSeverityMin), nil)
+	if err != nil {
+		serverNotifyIfErr("automodWarn::warnUser", err, m.GuildID, ds)
+		return ""
+	}
+	caseNumber := recordModCase(ds, ModCase{
+		GuildID:  m.GuildID,
+		ActorID:  ds.State.User.ID,
+		TargetID: m.Author.ID,
+		Action:   caseActionWarn,
+		Reason:   reason,
+		Source:   caseSourceAutomod,
+	})
+	sanction := fmt.Sprintf("\nWarning: #%d (case #%d)", warningID, caseNumber)
+
+	// the warning is sent before the policies, a kicked or banned user shares no server with the bot
+	if g, err := ds.State.Guild(m.GuildID); err == nil {
+		sendDirectMessage(m.Author.ID, fmt.Sprintf("**You have been warned in %s server** for the following reason:\n*%s*", g.Name, reason), ds)
+	}
+
+	policy, err := applyWarnPolicies(ds, m.GuildID, m.Author.ID)
+	serverNotifyIfErr("automodWarn::applyWarnPolicies", err, m.GuildID, ds)
+	if policy != nil {
+		sanction += fmt.Sprintf("\nAutomatic sanction applied: %s", policy)
+	}
+	return sanction
+}
+
+// countEmoji counts custom emoji and the most common unicode emoji ranges
+func countEmoji(content string) int {
+	count := len(automodCustomEmojiRegex.FindAllString(content, -1))
+	regionalIndicators := 0
+	for _, c := range content {
+		switch {
+		case c >= 0x1F1E6 && c <= 0x1F1FF:
+			// flags are made of two regional indicators
+			regionalIndicators++
+		case c >= 0x1F000 && c <= 0x1FAFF, c >= 0x2600 && c <= 0x27BF, c >= 0x2B00 && c <= 0x2BFF:
+			count++
+		}
+	}
+	return count + (regionalIndicators+1)/2
+}
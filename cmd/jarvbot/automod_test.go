@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func Test_automodCompiledRule_match(t *testing.T) {
+	type args struct {
+		matchType string
+		pattern   string
+		threshold int
+		content   string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Word alone", args{automodMatchWords, "bad, worse", 0, "bad"}, "bad"},
+		{"Word in a sentence", args{automodMatchWords, "bad, worse", 0, "that is WORSE!"}, "WORSE"},
+		{"Word inside another word", args{automodMatchWords, "bad", 0, "badminton is fun"}, ""},
+		{"Word with symbols", args{automodMatchWords, "c++", 0, "I write c++ code"}, "c++"},
+		{"Domain", args{automodMatchDomains, "example.com", 0, "see https://example.com/page"}, "example.com"},
+		{"Subdomain", args{automodMatchDomains, "https://www.example.com/", 0, "see http://cdn.Example.com:8080/x"}, "cdn.example.com"},
+		{"Domain with credentials", args{automodMatchDomains, "example.com", 0, "https://user@example.com"}, "example.com"},
+		{"Domain suffix of another domain", args{automodMatchDomains, "example.com", 0, "https://notexample.com"}, ""},
+		{"Caps over the threshold", args{automodMatchCaps, "", 70, "THIS IS VERY LOUD"}, "100% caps"},
+		{"Caps under the threshold", args{automodMatchCaps, "", 70, "This Is Not Very Loud"}, ""},
+		{"Caps with too few letters", args{automodMatchCaps, "", 70, "LOL OK"}, ""},
+		{"Caps with the default threshold", args{automodMatchCaps, "", 0, "MOSTLY CAPS here"}, "71% caps"},
+		{"Emoji over the threshold", args{automodMatchEmoji, "", 3, "😀😀 <:pepe:123>"}, "3 emoji"},
+		{"Flags count once", args{automodMatchEmoji, "", 3, "🇦🇷🇧🇷"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := compileAutomodRule(AutomodRule{
+				MatchType: tt.args.matchType,
+				Pattern:   tt.args.pattern,
+				Threshold: tt.args.threshold,
+				Action:    automodActionLog,
+			})
+			if err != nil {
+				t.Fatalf("compileAutomodRule() error = %v", err)
+			}
+			if got := rule.match(tt.args.content); got != tt.want {
+				t.Errorf("match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_compileAutomodRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    AutomodRule
+		wantErr bool
+	}{
+		{"Words", AutomodRule{MatchType: automodMatchWords, Pattern: "a, b", Action: automodActionDelete}, false},
+		{"Empty words", AutomodRule{MatchType: automodMatchWords, Pattern: " , ", Action: automodActionDelete}, true},
+		{"Invalid regex", AutomodRule{MatchType: automodMatchRegex, Pattern: "(", Action: automodActionDelete}, true},
+		{"Empty domains", AutomodRule{MatchType: automodMatchDomains, Pattern: "https://", Action: automodActionDelete}, true},
+		{"Unknown action", AutomodRule{MatchType: automodMatchInvites, Action: "ban"}, true},
+		{"Unknown match type", AutomodRule{MatchType: "vibes", Action: automodActionLog}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compileAutomodRule(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("compileAutomodRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_countEmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"Nothing", "hello", 0},
+		{"Unicode emoji", "😀 hi 🎉", 2},
+		{"Custom emoji", "<:pepe:123> <a:dance:456>", 2},
+		{"Flags", "🇦🇷🇧🇷", 2},
+		{"Symbols", "☀ ⭐", 2},
+		{"Mixed", "🇦🇷 😀 <:pepe:123>", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countEmoji(tt.content); got != tt.want {
+				t.Errorf("countEmoji() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
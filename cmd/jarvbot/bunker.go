@@ -21,23 +21,22 @@ func answerLiquid(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx contex
 }
 
 func answerDon(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
-	timeoutRole, err := getTimeoutRole(ds, mc.GuildID)
+	timeoutRoleID, err := resolveTimeoutRoleID(ds, mc.GuildID)
 	serverNotifyIfErr("answerDon, couldn't get timeoutRole", err, mc.GuildID, ds)
 	if err != nil {
 		return false
 	}
 
-	if isMemberInRole(mc.Member, timeoutRole.ID) {
+	if isMemberTimedOut(mc.Member, timeoutRoleID) {
 		ds.ChannelMessageSend(mc.ChannelID, "Stay Realmed scum")
 		return false
 	}
 
-	err = ds.GuildMemberRoleAdd(mc.GuildID, mc.Author.ID, timeoutRole.ID)
+	_, err = sendToShadowRealm(ds, mc.GuildID, mc.Author.ID, timeoutRoleID, 10*time.Minute, mc.Author.ID, caseSourceDon, "")
 	serverNotifyIfErr("answerDon, couldn't add timeoutRole", err, mc.GuildID, ds)
 	if err != nil {
 		return false
 	}
-	removeShadowRealmRoleAfterDuration(mc.GuildID, mc.Author.ID, timeoutRole.ID, 10*time.Minute)
 	_, err = ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("To the Shadow Realm you go %s", mc.Author.Mention()))
 	return err == nil
 }
@@ -49,7 +48,7 @@ func answerShoot(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context
 		return false
 	}
 
-	timeoutRole, err := getTimeoutRole(ds, mc.GuildID)
+	timeoutRoleID, err := resolveTimeoutRoleID(ds, mc.GuildID)
 	serverNotifyIfErr("answerShoot: get timeout role", err, mc.GuildID, ds)
 	if err != nil {
 		ds.ChannelMessageSend(mc.ChannelID, "Could not find the Timeout Role, maybe I'm missing permissions or it does not exist :(")
@@ -70,19 +69,19 @@ func answerShoot(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context
 		return false
 	}
 
-	err = shoot(ds, mc.ChannelID, mc.GuildID, shooter, target, timeoutRole.ID)
+	err = shoot(ds, mc.ChannelID, mc.GuildID, shooter, target, timeoutRoleID)
 	serverNotifyIfErr("answerShoot: shoot", err, mc.GuildID, ds)
 	return err == nil
 }
 
 func answerForceNuke(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
-	timeoutRole, err := getTimeoutRole(ds, mc.GuildID)
+	timeoutRoleID, err := resolveTimeoutRoleID(ds, mc.GuildID)
 	serverNotifyIfErr("answerForceNuke: get timeout role", err, mc.GuildID, ds)
 	if err != nil {
 		ds.ChannelMessageSend(mc.ChannelID, "Could not find the Timeout Role, maybe I'm missing permissions or it does not exist :(")
 		return false
 	}
-	return handleNuke(ds, mc.ChannelID, mc.GuildID, timeoutRole.ID, nuclearCatastropheResponse) == nil
+	return handleNuke(ds, mc.ChannelID, mc.GuildID, timeoutRoleID, nuclearCatastropheResponse, mc.Author.ID) == nil
 }
 
 func answerSniperShoot(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
@@ -103,15 +102,14 @@ func answerSniperShoot(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx c
 		return false
 	}
 
-	timeoutRole, err := getTimeoutRole(ds, bunkerServerID)
+	timeoutRoleID, err := resolveTimeoutRoleID(ds, bunkerServerID)
 	serverNotifyIfErr("answerSniperShoot: get timeout role", err, mc.GuildID, ds)
 	if err != nil {
 		return false
 	}
 
 	ds.ChannelMessageSend(bunkerGeneralChannelID, fmt.Sprintf("%s got sniped by %s!", target.User.Mention(), mc.Author.Mention()))
-	ds.GuildMemberRoleAdd(bunkerServerID, target.User.ID, timeoutRole.ID)
-	removeShadowRealmRoleAfterDuration(bunkerServerID, target.User.ID, timeoutRole.ID, timeoutDurationWhenShot)
+	sendToShadowRealm(ds, bunkerServerID, target.User.ID, timeoutRoleID, timeoutDurationWhenShot, mc.Author.ID, caseSourceSnipe, "")
 	ds.ChannelMessageSend(mc.ChannelID, "https://tenor.com/view/gun-anime-sniper-scope-scoping-gif-17545837")
 	return true
 }
@@ -132,6 +130,39 @@ func getTimeoutRoleName(ds *discordgo.Session, guildID string) string {
 	return timeoutRole.Name
 }
 
+func getTimeoutMode(guildID string) string {
+	mode, err := serverDS.getServerProperty(guildID, serverPropTimeoutMode)
+	if err != nil || mode == "" {
+		return timeoutModeRole
+	}
+	return mode
+}
+
+// resolveTimeoutRoleID returns an empty ID when the guild only uses native timeouts,
+// or when it uses both and the role is missing, so the native timeout still works
+func resolveTimeoutRoleID(ds *discordgo.Session, guildID string) (string, error) {
+	mode := getTimeoutMode(guildID)
+	if mode == timeoutModeNative {
+		return "", nil
+	}
+	timeoutRole, err := getTimeoutRole(ds, guildID)
+	if err != nil {
+		if mode == timeoutModeBoth {
+			return "", nil
+		}
+		return "", err
+	}
+	return timeoutRole.ID, nil
+}
+
+// isMemberTimedOut checks both the timeout role (if any) and Discord's native timeout
+func isMemberTimedOut(member *discordgo.Member, timeoutRoleID string) bool {
+	if timeoutRoleID != "" && isMemberInRole(member, timeoutRoleID) {
+		return true
+	}
+	return member.CommunicationDisabledUntil != nil && member.CommunicationDisabledUntil.After(time.Now())
+}
+
 func setCustomTimeoutRole(ds *discordgo.Session, guildID string, roleName string) error {
 	return serverDS.setServerProperty(guildID, serverPropCustomTimeoutRoleName, roleName)
 }
@@ -139,12 +170,12 @@ func setCustomTimeoutRole(ds *discordgo.Session, guildID string, roleName string
 // Internal functions
 
 func shoot(ds *discordgo.Session, channelID string, guildID string, shooter *discordgo.Member, target *discordgo.Member, timeoutRoleID string) error {
-	if isMemberInRole(shooter, timeoutRoleID) {
+	if isMemberTimedOut(shooter, timeoutRoleID) {
 		ds.ChannelMessageSend(channelID, "Shadow Realmed people can't shoot dummy")
 		return nil
 	}
 
-	if isMemberInRole(target, timeoutRoleID) {
+	if isMemberTimedOut(target, timeoutRoleID) {
 		ds.ChannelMessageSend(channelID, "https://giphy.com/gifs/the-simpsons-stop-hes-already-dead-JCAZQKoMefkoX6TyTb")
 		return nil
 	}
@@ -159,39 +190,31 @@ func shoot(ds *discordgo.Session, channelID string, guildID string, shooter *dis
 
 	// Nuke logic
 	if rand.Float32() <= nuclearCatastropheChance*nukeAFMultiplier {
-		return handleNuke(ds, channelID, guildID, timeoutRoleID, nuclearCatastropheResponse)
+		return handleNuke(ds, channelID, guildID, timeoutRoleID, nuclearCatastropheResponse, shooter.User.ID)
 	}
 
 	// Crit shot
 	if rand.Float32() <= shootCritChance*shootAFMultiplier {
 		ds.ChannelMessageSend(channelID, fmt.Sprintf("%s got shot!! Critical Hit!!", target.User.Mention()))
-		err := ds.GuildMemberRoleAdd(guildID, target.User.ID, timeoutRoleID)
-		if err == nil {
-			removeShadowRealmRoleAfterDuration(guildID, target.User.ID, timeoutRoleID, timeoutDurationWhenCritShot)
-		}
+		sendToShadowRealm(ds, guildID, target.User.ID, timeoutRoleID, timeoutDurationWhenCritShot, shooter.User.ID, caseSourceShot, "Critical hit")
 		return nil
 	}
 
 	// Miss logic
 	if rand.Float32() <= shootMisfireChance*shootAFMultiplier || target.User.Bot {
 		ds.ChannelMessageSend(channelID, "OOPS! You missed :3c")
-		err := ds.GuildMemberRoleAdd(guildID, shooter.User.ID, timeoutRoleID)
-		if err == nil {
-			removeShadowRealmRoleAfterDuration(guildID, shooter.User.ID, timeoutRoleID, timeoutDurationWhenMisfire)
-		}
+		sendToShadowRealm(ds, guildID, shooter.User.ID, timeoutRoleID, timeoutDurationWhenMisfire, shooter.User.ID, caseSourceMisfire, "")
 		return nil
 	}
 
 	// Normal shot
 	ds.ChannelMessageSend(channelID, fmt.Sprintf("%s got shot!", target.User.Mention()))
-	err := ds.GuildMemberRoleAdd(guildID, target.User.ID, timeoutRoleID)
-	if err == nil {
-		removeShadowRealmRoleAfterDuration(guildID, target.User.ID, timeoutRoleID, timeoutDurationWhenShot)
-	}
+	sendToShadowRealm(ds, guildID, target.User.ID, timeoutRoleID, timeoutDurationWhenShot, shooter.User.ID, caseSourceShot, "")
 	return nil
 }
 
-func handleNuke(ds *discordgo.Session, channelID, guildID, timeoutRoleID, firstResponse string) error {
+// handleNuke sends some active channel members to the Shadow Realm, actorID is who caused the explosion
+func handleNuke(ds *discordgo.Session, channelID, guildID, timeoutRoleID, firstResponse, actorID string) error {
 	ds.ChannelMessageSend(channelID, firstResponse)
 
 	activeUsers, err := activeChannelMembers(ds, channelID, false)
@@ -218,9 +241,7 @@ func handleNuke(ds *discordgo.Session, channelID, guildID, timeoutRoleID, firstR
 
 	for _, user := range dead {
 		ds.ChannelMessageSend(channelID, fmt.Sprintf("%s died in the explosion!", user.Mention()))
-		if err := ds.GuildMemberRoleAdd(guildID, user.ID, timeoutRoleID); err == nil {
-			removeShadowRealmRoleAfterDuration(guildID, user.ID, timeoutRoleID, timeoutDurationWhenNuclearCatastrophe)
-		}
+		sendToShadowRealm(ds, guildID, user.ID, timeoutRoleID, timeoutDurationWhenNuclearCatastrophe, actorID, caseSourceNuke, "")
 	}
 
 	return nil
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/j4rv/discord-bot/pkg/rngx"
+)
+
+// Command Answers
+
+// Format: !random <collection>
+func answerRandomFromCollection(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Please tell me the collection, for example: !random cats")
+		return false
+	}
+
+	collection, err := commandDS.commandCollection(mc.GuildID, args[0])
+	if err == sql.ErrNoRows {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that collection! sowwy u_u")
+		return false
+	}
+	if err != nil {
+		serverNotifyIfErr("answerRandomFromCollection::commandCollection", err, mc.GuildID, ds)
+		return false
+	}
+
+	entries, err := commandDS.commandCollectionEntries(collection.ID)
+	if err != nil {
+		serverNotifyIfErr("answerRandomFromCollection::commandCollectionEntries", err, mc.GuildID, ds)
+		return false
+	}
+	if len(entries) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "That collection is empty :(")
+		return false
+	}
+
+	key := pickFromCollection(mc.ChannelID, collection.ID, entries)
+	answer, err := simpleCommandAnswer(key, mc.GuildID)
+	serverNotifyIfErr("answerRandomFromCollection::simpleCommandAnswer", err, mc.GuildID, ds)
+	if answer == nil {
+		return false
+	}
+	return answer(ds, mc, ctx)
+}
+
+func answerListCollections(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	collections, err := commandDS.guildCommandCollections(mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerListCollections", err, mc.GuildID, ds)
+		return false
+	}
+	if len(collections) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "No collections yet! Mods can make one with !addcollection")
+		return true
+	}
+
+	items := []string{"Name", "Commands"}
+	for _, c := range collections {
+		items = append(items, c.Name, strconv.Itoa(c.EntryCount))
+	}
+	_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, &discordgo.MessageEmbed{
+		Title:       "Command collections",
+		Description: "```" + formatInColumns(items, 2, true) + "```",
+	})
+	return err == nil
+}
+
+// Format: !collection <collection>
+func answerCheckCollection(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) == 0 {
+		return answerListCollections(ds, mc, ctx)
+	}
+
+	collection, err := commandDS.commandCollection(mc.GuildID, args[0])
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that collection! sowwy u_u")
+		return false
+	}
+	entries, err := commandDS.commandCollectionEntries(collection.ID)
+	if err != nil {
+		serverNotifyIfErr("answerCheckCollection::commandCollectionEntries", err, mc.GuildID, ds)
+		return false
+	}
+	if len(entries) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "That collection is empty, mods can add commands with !tagcommand")
+		return true
+	}
+
+	totalWeight := 0
+	for _, e := range entries {
+		totalWeight += e.Weight
+	}
+	items := []string{"Command", "Weight", "Chance"}
+	for _, e := range entries {
+		items = append(items, e.CommandKey, strconv.Itoa(e.Weight), fmt.Sprintf("%.2f%%", divideToFloat(e.Weight*100, totalWeight)))
+	}
+	_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, &discordgo.MessageEmbed{
+		Title:       "Collection " + collection.Name,
+		Description: "```" + formatInColumns(items, 3, true) + "```",
+	})
+	return err == nil
+}
+
+// Format: !addcollection <collection>
+func answerAddCollection(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) != 1 {
+		ds.ChannelMessageSend(mc.ChannelID, "Please give the collection a name without spaces, for example: !addcollection cats")
+		return false
+	}
+	name := strings.ToLower(args[0])
+	if len(name) > commandCollectionNameMaxLength {
+		ds.ChannelMessageSend(mc.ChannelID, "That collection name is too long! :<")
+		return false
+	}
+
+	current, err := commandDS.guildCommandCollections(mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerAddCollection::guildCommandCollections", err, mc.GuildID, ds)
+		return false
+	}
+	if len(current) >= commandCollectionsMaxPerGuild {
+		ds.ChannelMessageSend(mc.ChannelID, "Too many collections!, please clean up before adding more :3")
+		return false
+	}
+
+	err = commandDS.addCommandCollection(mc.GuildID, name, mc.Author.ID)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not create the collection: "+err.Error())
+		return false
+	}
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+// Format: !removecollection <collection>
+func answerRemoveCollection(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) != 1 {
+		ds.ChannelMessageSend(mc.ChannelID, "Please tell me the collection, for example: !removecollection cats")
+		return false
+	}
+
+	err := commandDS.removeCommandCollection(mc.GuildID, args[0])
+	if err == sql.ErrNoRows {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that collection! sowwy u_u")
+		return false
+	}
+	serverNotifyIfErr("answerRemoveCollection", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	}
+	return err == nil
+}
+
+// Format: !tagcommand <collection> !key [weight]
+func answerTagCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) < 2 {
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !tagcommand <collection> !command [weight]")
+		return false
+	}
+
+	weight := 1
+	if len(args) > 2 {
+		var err error
+		weight, err = strconv.Atoi(args[2])
+		if err != nil || weight <= 0 || weight > commandCollectionMaxWeight {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The weight must be a number between 1 and %d", commandCollectionMaxWeight))
+			return false
+		}
+	}
+
+	collection, err := commandDS.commandCollection(mc.GuildID, args[0])
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that collection! sowwy u_u")
+		return false
+	}
+
+	key := args[1]
+	exists, err := commandDS.simpleCommandExists(key, mc.GuildID)
+	if err != nil || !exists {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that command! sowwy u_u")
+		return false
+	}
+
+	err = commandDS.tagCommand(collection.ID, key, weight)
+	serverNotifyIfErr("answerTagCommand::tagCommand", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	}
+	return err == nil
+}
+
+// Format: !untagcommand <collection> !key
+func answerUntagCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) != 2 {
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !untagcommand <collection> !command")
+		return false
+	}
+
+	collection, err := commandDS.commandCollection(mc.GuildID, args[0])
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that collection! sowwy u_u")
+		return false
+	}
+
+	err = commandDS.untagCommand(collection.ID, args[1])
+	if err == errZeroRowsAffected {
+		ds.ChannelMessageSend(mc.ChannelID, "That command is not in the collection u_u")
+		return false
+	}
+	serverNotifyIfErr("answerUntagCommand::untagCommand", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	}
+	return err == nil
+}
+
+// Internal functions
+
+var recentCollectionPicks = map[string][]string{}
+var recentCollectionPicksMutex sync.Mutex
+
+// pickFromCollection does a weighted pick, avoiding the last picks in the same channel when possible
+func pickFromCollection(channelID string, collectionID int, entries []CommandCollectionEntry) string {
+	recentKey := fmt.Sprintf("%s;%d", channelID, collectionID)
+
+	recentCollectionPicksMutex.Lock()
+	defer recentCollectionPicksMutex.Unlock()
+
+	recent := recentCollectionPicks[recentKey]
+	avoidCount := min(commandCollectionNoRepeatCount, len(entries)-1, len(recent))
+	avoid := recent[len(recent)-avoidCount:]
+
+	weights := rngx.WeightedSlice[string]{}
+	for _, e := range entries {
+		if slices.Contains(avoid, strings.ToLower(e.CommandKey)) {
+			continue
+		}
+		weights.Entries = append(weights.Entries, rngx.WeightedSliceEntry[string]{Value: e.CommandKey, Weight: e.Weight})
+	}
+	weights.CalcWeight()
+
+	picked := weights.Random(rand.New(rand.NewSource(time.Now().UnixNano())))
+
+	recent = append(recent, strings.ToLower(picked))
+	if len(recent) > commandCollectionNoRepeatCount {
+		recent = recent[len(recent)-commandCollectionNoRepeatCount:]
+	}
+	recentCollectionPicks[recentKey] = recent
+	return picked
+}
@@ -12,8 +12,10 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -52,7 +54,29 @@ func onMessageCreated(ctx context.Context) func(ds *discordgo.Session, mc *disco
 			}
 		}()
 
-		if mc == nil || mc.Author == nil || mc.Author.Bot || len(mc.Content) == 0 {
+		if mc == nil || mc.Author == nil || mc.Author.Bot {
+			return
+		}
+
+		if shouldCacheMessage(mc.Message) {
+			go cacheGuildMessage(ds, mc.Message)
+		}
+		archiveMessageAttachments(ds, mc.Message)
+
+		if checkSpam(ds, mc.Message) {
+			return
+		}
+
+		// DMs that are not commands are forwarded to the staff of the user's servers, if any accepts modmail
+		if mc.GuildID == "" && !strings.HasPrefix(mc.Content, "!") && relayModmail(ds, mc.Message) {
+			return
+		}
+
+		if len(mc.Content) == 0 {
+			return
+		}
+
+		if applyAutomod(ds, mc.Message) {
 			return
 		}
 
@@ -100,39 +124,84 @@ var commands = map[string]command{
 	"!qr":                        notSpammable(answerQR),
 	"!minesweeper":               notSpammable(answerMinesweeper),
 	"!minesweepercredits":        notSpammable(simpleTextResponse("Credits to @heathcliff26: https://github.com/heathcliff26/go-minesweeper")),
+	"!proposecommand":            guildOnly(notSpammable(answerProposeCommand)),
+	"!random":                    guildOnly(notSpammable(answerRandomFromCollection)),
+	"!collections":               guildOnly(notSpammable(answerListCollections)),
+	"!collection":                guildOnly(notSpammable(answerCheckCollection)),
+	"!realmstatus":               guildOnly(notSpammable(answerRealmStatus)),
+	"!realmed":                   guildOnly(notSpammable(answerRealmed)),
 	// hidden or easter eggs
 	"!hello":        notSpammable(answerHello),
 	"!liquid":       notSpammable(answerLiquid),
 	"!don":          notSpammable(answerDon),
 	"!sniper_shoot": notSpammable(answerSniperShoot),
-	// only available for discord mods
+	// only available for discord mods, the ones wrapped by helperOnly are also available for helpers
 	"!addmod":               guildOnly(modOnly(answerAddMod)),
 	"!removemod":            guildOnly(modOnly(answerRemoveMod)),
-	"!checkmods":            guildOnly(modOnly(answerCheckMods)),
-	"!roleids":              guildOnly(modOnly(answerRoleIDs)),
+	"!checkmods":            guildOnly(helperOnly(answerCheckMods)),
+	"!addmodrole":           guildOnly(modOnly(answerAddModRole)),
+	"!removemodrole":        guildOnly(modOnly(answerRemoveModRole)),
+	"!addhelperrole":        guildOnly(modOnly(answerAddHelperRole)),
+	"!removehelperrole":     guildOnly(modOnly(answerRemoveHelperRole)),
+	"!roleids":              guildOnly(helperOnly(answerRoleIDs)),
 	"!react4roles":          guildOnly(modOnly(answerMakeReact4RolesMsg)),
+	"!react4rolesadd":       guildOnly(modOnly(answerAddReact4Roles)),
+	"!react4rolesremove":    guildOnly(modOnly(answerRemoveReact4Roles)),
+	"!react4rolesreplace":   guildOnly(modOnly(answerReplaceReact4Roles)),
 	"!addcommand":           guildOnly(modOnly(answerAddCommand)),
 	"!replacecommand":       guildOnly(modOnly(answerReplaceCommand)),
+	"!addembedcommand":      guildOnly(modOnly(answerAddEmbedCommand)),
+	"!replaceembedcommand":  guildOnly(modOnly(answerReplaceEmbedCommand)),
 	"!removecommand":        guildOnly(modOnly(answerRemoveCommand)),
 	"!deletecommand":        guildOnly(modOnly(answerRemoveCommand)),
 	"!commandcreator":       guildOnly(modOnly(answerCommandCreator)),
+	"!commandsettings":      guildOnly(modOnly(answerCommandSettings)),
+	"!addcollection":        guildOnly(modOnly(answerAddCollection)),
+	"!removecollection":     guildOnly(modOnly(answerRemoveCollection)),
+	"!tagcommand":           guildOnly(modOnly(answerTagCommand)),
+	"!untagcommand":         guildOnly(modOnly(answerUntagCommand)),
 	"!listservercommands":   guildOnly(notSpammable(answerListGuildCommands)),
 	"!listcommands":         notSpammable(answerListCommands),
 	"!listglobalcommands":   notSpammable(answerListGlobalCommands),
 	"!allowspamming":        guildOnly(modOnly(answerAllowSpamming)),
 	"!preventspamming":      guildOnly(modOnly(answerPreventSpamming)),
 	"!setcustomtimeoutrole": guildOnly(modOnly(answerSetCustomTimeoutRole)),
+	"!settimeoutmode":       guildOnly(modOnly(answerSetTimeoutMode)),
+	"!release":              guildOnly(modOnly(answerRelease)),
 	"!errorshere":           guildOnly(modOnly(answerErrorsHere)),
 	"!testerror":            guildOnly(modOnly(answerTestError)),
 	"!announcehere":         guildOnly(modOnly(answerAnnounceHere)),
 	"!fixbadembedlinks":     guildOnly(modOnly(answerFixBadEmbedLinks)),
 	"!messagelogs":          guildOnly(modOnly(answerMessageLogs)),
-	"!commandstats":         guildOnly(modOnly(answerCommandStats)),
+	"!messagecache":         guildOnly(modOnly(answerMessageCache)),
+	"!attachmentarchive":    guildOnly(modOnly(answerAttachmentArchive)),
+	"!antispam":             guildOnly(modOnly(answerAntiSpam)),
+	"!antiraid":             guildOnly(modOnly(answerAntiRaid)),
+	"!lockdown":             guildOnly(modOnly(answerLockdown)),
+	"!unlock":               guildOnly(modOnly(answerUnlock)),
+	"!welcome":              guildOnly(modOnly(answerWelcome)),
+	"!autoroles":            guildOnly(modOnly(answerAutoRoles)),
+	"!logs":                 guildOnly(modOnly(answerLogs)),
+	"!logignore":            guildOnly(modOnly(answerLogIgnore)),
+	"!logeditthreshold":     guildOnly(modOnly(answerLogEditThreshold)),
+	"!logredact":            guildOnly(modOnly(answerLogRedact)),
+	"!commandstats":         guildOnly(helperOnly(answerCommandStats)),
 	"!placemines":           guildOnly(modOnly(answerPlaceMines)),
-	"!checkmines":           guildOnly(modOnly(answerCheckMines)),
+	"!checkmines":           guildOnly(helperOnly(answerCheckMines)),
 	"!removemines":          guildOnly(modOnly(answerRemoveMines)),
 	"!removeservermines":    guildOnly(modOnly(answerRemoveGuildMines)),
-	"!findcommand":          guildOnly(modOnly(answerFindCommand)),
+	"!findcommand":          guildOnly(helperOnly(answerFindCommand)),
+	"!commandproposalshere": guildOnly(modOnly(answerCommandProposalsHere)),
+	"!modmailhere":          guildOnly(modOnly(answerModmailHere)),
+	"!modmailstop":          guildOnly(modOnly(answerModmailStop)),
+	"!reply":                guildOnly(helperOnly(answerModmailReply)),
+	"!areply":               guildOnly(helperOnly(answerModmailAnonymousReply)),
+	"!close":                guildOnly(helperOnly(answerModmailClose)),
+	"!modmailtranscript":    guildOnly(helperOnly(answerModmailTranscript)),
+	"!modmailhistory":       guildOnly(helperOnly(answerModmailHistory)),
+	"!addwarnpolicy":        guildOnly(modOnly(answerAddWarnPolicy)),
+	"!warnpolicies":         guildOnly(helperOnly(answerWarnPolicies)),
+	"!removewarnpolicy":     guildOnly(modOnly(answerRemoveWarnPolicy)),
 	// only available for the bot owner
 	//"!setserverprop":       adminOnly(answerSetServerProp),
 	"!nuketest":            guildOnly(adminOnly(answerForceNuke)),
@@ -183,16 +252,28 @@ func processCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx cont
 		}
 	}
 
-	response, err := commandDS.simpleCommandResponse(commandKey, mc.GuildID)
-	adminNotifyIfErr("simpleCommandResponse", err, ds)
-	if err == nil {
-		if notSpammable(simpleTextResponse(response))(ds, mc, ctx) {
-			onSuccessCommandCall(mc, commandKey)
-			log.Printf("[%s] [%s] %s", mc.ChannelID, mc.Author.Username, commandKey)
-		}
+	answer, err := simpleCommandAnswer(commandKey, mc.GuildID)
+	adminNotifyIfErr("simpleCommandAnswer", err, ds)
+	if answer != nil && notSpammable(answer)(ds, mc, ctx) {
+		onSuccessCommandCall(mc, commandKey)
+		log.Printf("[%s] [%s] %s", mc.ChannelID, mc.Author.Username, commandKey)
 	}
 }
 
+// simpleCommandAnswer returns nil if the custom command does not exist
+func simpleCommandAnswer(commandKey, guildID string) (command, error) {
+	simpleCommand, err := commandDS.simpleCommand(commandKey, guildID)
+	if err != nil || (simpleCommand.Response == "" && simpleCommand.Embed == "") {
+		return nil, err
+	}
+
+	settings, err := commandDS.simpleCommandSettings(commandKey, guildID)
+	if err != nil {
+		return nil, err
+	}
+	return restrictedCommand(settings, simpleCommandResponder(simpleCommand)), nil
+}
+
 func processBotMention(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) {
 	lowercaseContent := strings.ToLower(mc.Content)
 	if !strings.Contains(lowercaseContent, "?") {
@@ -358,6 +439,26 @@ func answerSetCustomTimeoutRole(ds *discordgo.Session, mc *discordgo.MessageCrea
 	return err == nil
 }
 
+// Format: !settimeoutmode role|native|both
+func answerSetTimeoutMode(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	mode := strings.ToLower(strings.TrimSpace(commandPrefixRegex.ReplaceAllString(mc.Content, "")))
+	if !slices.Contains([]string{timeoutModeRole, timeoutModeNative, timeoutModeBoth}, mode) {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Current timeout mode: %s\nFormat: !settimeoutmode role|native|both", getTimeoutMode(mc.GuildID)))
+		return false
+	}
+
+	err := serverDS.setServerProperty(mc.GuildID, serverPropTimeoutMode, mode)
+	serverNotifyIfErr("answerSetTimeoutMode", err, mc.GuildID, ds)
+	if err == nil {
+		response := fmt.Sprintf("Timeout mode set to '%s'", mode)
+		if mode != timeoutModeRole {
+			response += "\nRemember that I need the Timeout Members permission for native timeouts :3"
+		}
+		ds.ChannelMessageSend(mc.ChannelID, response)
+	}
+	return err == nil
+}
+
 func answerAnnounceHere(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
 	err := serverDS.setServerProperty(mc.GuildID, serverPropAnnounceHere, mc.ChannelID)
 	serverNotifyIfErr("answerAnnounceHere", err, mc.GuildID, ds)
@@ -638,6 +739,8 @@ func answerRemoveCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx
 	}
 	serverNotifyIfErr("removeSimpleCommand", err, mc.GuildID, ds)
 	if err == nil {
+		commandDS.removeSimpleCommandSettings(commandBody, mc.GuildID)
+		commandDS.untagCommandFromGuildCollections(commandBody, mc.GuildID)
 		ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
 	}
 	return err == nil
@@ -664,6 +767,133 @@ func answerCommandCreator(ds *discordgo.Session, mc *discordgo.MessageCreate, ct
 	return true
 }
 
+type commandSettingsInput struct {
+	Channels string `short:"c" long:"channels" description:"Channels where the command can be used, separated by spaces or commas. 'none' removes the restriction"`
+	Roles    string `short:"r" long:"roles" description:"Roles that can use the command, separated by spaces or commas. 'none' removes the restriction"`
+	Cooldown string `short:"d" long:"cooldown" description:"Cooldown of the command, format: 99h99m99s. 0s removes the cooldown"`
+	Scope    string `short:"s" long:"scope" choice:"channel" choice:"user" choice:"guild" description:"Who shares the cooldown"`
+	NSFW     string `long:"nsfw" choice:"yes" choice:"no" description:"If yes, the command only works in NSFW channels"`
+	Reset    bool   `long:"reset" description:"Removes all the restrictions of the command"`
+}
+
+// Format: !commandsettings !key [flags]
+// Without flags, it shows the current settings of the command
+func answerCommandSettings(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var input commandSettingsInput
+	if err := parseCommandArgs(&input, mc.Content); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+
+	commandBody := commandPrefixRegex.ReplaceAllString(mc.Content, "")
+	key := strings.TrimSpace(commandPrefixRegex.FindString(commandBody))
+	if key == "" {
+		ds.ChannelMessageSend(mc.ChannelID, "Please provide the command, for example: !commandsettings !mycommand -d 1h")
+		return false
+	}
+
+	exists, err := commandDS.simpleCommandExists(key, mc.GuildID)
+	if err != nil || !exists {
+		ds.ChannelMessageSend(mc.ChannelID, "I could not find that command! sowwy u_u")
+		return false
+	}
+
+	if input.Reset {
+		err = commandDS.removeSimpleCommandSettings(key, mc.GuildID)
+		serverNotifyIfErr("answerCommandSettings::removeSimpleCommandSettings", err, mc.GuildID, ds)
+		if err == nil {
+			ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+		}
+		return err == nil
+	}
+
+	settings, err := commandDS.simpleCommandSettings(key, mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerCommandSettings::simpleCommandSettings", err, mc.GuildID, ds)
+		return false
+	}
+
+	changed := false
+	if input.Channels != "" {
+		channelIDs := extractDiscordIDs(input.Channels)
+		for _, id := range channelIDs {
+			if !channelBelongsToGuild(ds, id, mc.GuildID) {
+				ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The channel %s doesn't belong to this server", id))
+				return false
+			}
+		}
+		settings.AllowedChannelIDs = strings.Join(channelIDs, serverPropListSeparator)
+		changed = true
+	}
+	if input.Roles != "" {
+		settings.AllowedRoleIDs = strings.Join(extractDiscordIDs(input.Roles), serverPropListSeparator)
+		changed = true
+	}
+	if input.Cooldown != "" {
+		cooldown, ok := parseDurationFlag(input.Cooldown)
+		if !ok {
+			ds.ChannelMessageSend(mc.ChannelID, "The cooldown must be a duration like 30s or 5m, 0s removes it")
+			return false
+		}
+		settings.CooldownSeconds = int(cooldown.Seconds())
+		changed = true
+	}
+	if input.Scope != "" {
+		settings.CooldownScope = input.Scope
+		changed = true
+	}
+	if input.NSFW != "" {
+		settings.NSFW = input.NSFW == "yes"
+		changed = true
+	}
+
+	if changed {
+		settings.Key = key
+		settings.GuildID = mc.GuildID
+		err = commandDS.setSimpleCommandSettings(settings)
+		if err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Could not save the command settings: "+err.Error())
+			return false
+		}
+	}
+
+	_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, simpleCommandSettingsEmbed(key, settings))
+	return err == nil
+}
+
+func simpleCommandSettingsEmbed(key string, settings SimpleCommandSettings) *discordgo.MessageEmbed {
+	formatIDs := func(ids []string, mentionFormat string) string {
+		if len(ids) == 0 {
+			return "Any"
+		}
+		formatted := make([]string, len(ids))
+		for i, id := range ids {
+			formatted[i] = fmt.Sprintf(mentionFormat, id)
+		}
+		return strings.Join(formatted, " ")
+	}
+
+	cooldown := "None"
+	if settings.CooldownSeconds > 0 {
+		cooldown = fmt.Sprintf("%s per %s", humanDurationString(time.Duration(settings.CooldownSeconds)*time.Second), settings.CooldownScope)
+	}
+	nsfw := "No"
+	if settings.NSFW {
+		nsfw = "Yes"
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "Settings for " + key,
+		Color: colorBlue,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Channels", Value: formatIDs(settings.AllowedChannels(), "<#%s>")},
+			{Name: "Roles", Value: formatIDs(settings.AllowedRoles(), "<@&%s>")},
+			{Name: "Cooldown", Value: cooldown, Inline: true},
+			{Name: "NSFW only", Value: nsfw, Inline: true},
+		},
+	}
+}
+
 func answerRemoveGlobalCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
 	commandBody := strings.TrimSpace(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
 	err := commandDS.removeSimpleCommand(commandBody, globalGuildID)
@@ -856,6 +1086,16 @@ func modOnly(wrapped command) command {
 	}
 }
 
+func helperOnly(wrapped command) command {
+	return func(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+		if !(isAdmin(mc.Author.ID) || isHelper(ds, mc.Author.ID, mc.ChannelID)) {
+			ds.ChannelMessageSend(mc.ChannelID, userMustBeHelperMessage)
+			return false
+		}
+		return wrapped(ds, mc, ctx)
+	}
+}
+
 func modOrDmOnly(wrapped command) command {
 	return func(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
 		if !(isAdmin(mc.Author.ID) || mc.GuildID == "" || isMod(ds, mc.Author.ID, mc.ChannelID)) {
@@ -896,6 +1136,57 @@ func notSpammable(wrapped command) command {
 	}
 }
 
+// restrictedCommand applies the per-command settings that mods can configure with !commandsettings
+func restrictedCommand(settings SimpleCommandSettings, wrapped command) command {
+	return func(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+		if mc.GuildID == "" || !settings.IsRestricted() {
+			return wrapped(ds, mc, ctx)
+		}
+
+		if settings.NSFW || len(settings.AllowedChannels()) != 0 {
+			channel, err := ds.State.Channel(mc.ChannelID)
+			if err != nil {
+				channel, err = ds.Channel(mc.ChannelID)
+			}
+			if err != nil {
+				serverNotifyIfErr("restrictedCommand::Channel", err, mc.GuildID, ds)
+				return false
+			}
+			if settings.NSFW && !channel.NSFW {
+				ds.MessageReactionAdd(mc.ChannelID, mc.Message.ID, "🔞")
+				return false
+			}
+			allowedChannels := settings.AllowedChannels()
+			if len(allowedChannels) != 0 && !slices.Contains(allowedChannels, channel.ID) && !slices.Contains(allowedChannels, channel.ParentID) {
+				ds.MessageReactionAdd(mc.ChannelID, mc.Message.ID, "❌")
+				return false
+			}
+		}
+
+		allowedRoles := settings.AllowedRoles()
+		if len(allowedRoles) != 0 && (mc.Member == nil || !slices.ContainsFunc(allowedRoles, func(roleID string) bool {
+			return isMemberInRole(mc.Member, roleID)
+		})) {
+			ds.MessageReactionAdd(mc.ChannelID, mc.Message.ID, "❌")
+			return false
+		}
+
+		if settings.CooldownSeconds <= 0 {
+			return wrapped(ds, mc, ctx)
+		}
+		cooldownKey := simpleCommandCooldownKey(settings, mc)
+		if isSimpleCommandOnCooldown(cooldownKey) {
+			ds.MessageReactionAdd(mc.ChannelID, mc.Message.ID, "⏳")
+			return false
+		}
+		if wrapped(ds, mc, ctx) {
+			setSimpleCommandCooldown(cooldownKey, time.Duration(settings.CooldownSeconds)*time.Second)
+			return true
+		}
+		return false
+	}
+}
+
 // ---------- Cooldowns ----------
 
 var lastUserCommandTime = map[string]time.Time{}
@@ -911,3 +1202,38 @@ func isUserOnCooldown(userID string) bool {
 	}
 	return time.Now().Before(lastTime.Add(commandCooldown))
 }
+
+var simpleCommandCooldowns = map[string]time.Time{}
+var simpleCommandCooldownsMutex sync.Mutex
+
+func simpleCommandCooldownKey(settings SimpleCommandSettings, mc *discordgo.MessageCreate) string {
+	key := mc.GuildID + ";" + strings.ToLower(settings.Key)
+	switch settings.CooldownScope {
+	case cooldownScopeUser:
+		return key + ";" + mc.Author.ID
+	case cooldownScopeGuild:
+		return key
+	default:
+		return key + ";" + mc.ChannelID
+	}
+}
+
+func setSimpleCommandCooldown(cooldownKey string, cooldown time.Duration) {
+	simpleCommandCooldownsMutex.Lock()
+	defer simpleCommandCooldownsMutex.Unlock()
+	simpleCommandCooldowns[cooldownKey] = time.Now().Add(cooldown)
+}
+
+func isSimpleCommandOnCooldown(cooldownKey string) bool {
+	simpleCommandCooldownsMutex.Lock()
+	defer simpleCommandCooldownsMutex.Unlock()
+	until, ok := simpleCommandCooldowns[cooldownKey]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(simpleCommandCooldowns, cooldownKey)
+		return false
+	}
+	return true
+}
@@ -16,19 +16,73 @@ var strongboxMinAmount = 1.0
 var strongboxMaxAmount = 1000.0
 var warnMessageMinLength = 1
 var warnMessageMaxLength = 320
+var modNoteMaxLength = 1000
+var modNotesMaxPerUser = 50
+var warnSeverityMin = 1.0
+var warnSeverityMax = 3.0
+var warningsPageMin = 1.0
+var warningsPageMax = 1000.0
+var banDeleteDaysMin = 0.0
+var banDeleteDaysMax = 7.0
+var purgeCountMin = 1.0
+var purgeCountMax = 100.0
+var roleGroupLimitMin = 1.0
+var roleGroupLimitMax = 25.0
 var discordMessageMaxLength = 1900
 var commandKeyMaxLength = 32
 var maxServerUserMods = 15
+var maxServerStaffRoles = 10
+var commandProposalMaxPendingPerUser = 3
+var commandCollectionsMaxPerGuild = 50
+var commandCollectionNameMaxLength = 32
+var commandCollectionMaxWeight = 1000
+var commandCollectionNoRepeatCount = 3
+var attachmentArchiveDir = "attachment_archive"
+var attachmentArchiveMaxTotalMB = 5000
+var attachmentArchiveDefaultQuotaMB = 200
+var attachmentArchiveMaxQuotaMB = 1000
 
 const discordMaxMessageLength = 2000
+
+// https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const embedTitleMaxLength = 256
+const embedDescriptionMaxLength = 4096
+const embedMaxFields = 25
+const embedFieldNameMaxLength = 256
+const embedFieldValueMaxLength = 1024
+const embedFooterMaxLength = 2048
+const embedAuthorNameMaxLength = 256
+const embedTotalMaxLength = 6000
 const avatarTargetSize = "1024"
 
+// https://discord.com/developers/docs/interactions/message-components#text-inputs
+const modalTextInputMaxLength = 4000
+
+// https://discord.com/developers/docs/reference#uploading-files
+const discordMaxUploadSize = 10 * 1024 * 1024
+const discordMaxFilesPerMessage = 10
+const attachmentDownloadTimeout = time.Minute
+
 const cleanStateMessagesCRON = "0 * * * *"
+const purgeMessageCacheCRON = "30 * * * *"
+const messageCacheMaxRetention = 30 * 24 * time.Hour
 const stateMessageMaxLifetime = 2 * 24 * time.Hour
+const purgeAttachmentArchiveCRON = "45 * * * *"
 const maxMessageCount = 100
+const purgeMaxScannedMessages = 500
+
+// https://discord.com/developers/docs/resources/message#bulk-delete-messages
+const bulkDeleteMaxMessageAge = 14 * 24 * time.Hour
 const expensiveOperationCooldown = 15 * time.Second
 const commandCooldown = time.Minute * 15
 
+// role changes invalidate it, the TTL only covers the events missed while disconnected
+const staffCacheTTL = 10 * time.Minute
+const pruneStaffCacheCRON = "*/30 * * * *"
+const cooldownScopeChannel = "channel"
+const cooldownScopeUser = "user"
+const cooldownScopeGuild = "guild"
+
 // https://discord.com/branding
 const colorBlue = 0x5865F2
 const colorYellow = 0xFEE75C
@@ -38,16 +92,51 @@ const colorBlack = 0x242428
 
 const serverPropListSeparator = ";"
 const serverPropCustomTimeoutRoleName = "custom_timeout_role_name"
+const serverPropTimeoutMode = "timeout_mode"
 const serverPropErrorsHere = "errors_here"
 const serverPropAnnounceHere = "announce_here"
 const serverPropMessageLogs = "message_logs"
+const serverPropLogCategoryPrefix = "log_"
+const serverPropLogIgnoredChannels = "message_logs_ignored_channel_ids"
+const serverPropLogIgnoredRoles = "message_logs_ignored_role_ids"
+const serverPropLogIgnoredUsers = "message_logs_ignored_user_ids"
+const serverPropLogMinEditDistance = "message_logs_min_edit_distance"
+const serverPropLogRedactPatterns = "message_logs_redact_patterns"
+const serverPropPatternSeparator = "\n"
+const serverPropMessageCache = "message_cache"
+const serverPropMessageCacheRetention = "message_cache_retention_seconds"
+const serverPropAttachmentArchive = "attachment_archive"
+const serverPropAttachmentArchiveQuota = "attachment_archive_quota_mb"
+const serverPropRaidJoinThreshold = "raid_join_threshold"
+const serverPropRaidJoinWindow = "raid_join_window_seconds"
+const serverPropRaidAutoLockdown = "raid_auto_lockdown"
+const serverPropRaidLockdownDuration = "raid_lockdown_duration_seconds"
+const serverPropLockdownChannels = "lockdown_channel_ids"
 const serverPropFixBadEmbedLinks = "fix_twitter_links"
 const serverPropMaxSimpleCommands = "max_simple_commands"
 const serverPropYes = "Y"
 const serverPropNo = "N"
 const serverPropMods = "mod_user_ids"
+const serverPropModRoles = "mod_role_ids"
+const serverPropHelperRoles = "helper_role_ids"
+const serverPropCommandProposals = "command_proposals"
+const serverPropModmailChannel = "modmail_channel_id"
+const serverPropWelcomeChannel = "welcome_channel_id"
+const serverPropWelcomeMessage = "welcome_message"
+const serverPropGoodbyeMessage = "goodbye_message"
+const serverPropWelcomeDM = "welcome_dm"
+const serverPropAutoRoles = "auto_role_ids"
+const serverPropAutoRoleDelay = "auto_role_delay_seconds"
+const serverPropMinAccountAge = "min_account_age_seconds"
+const serverPropQuarantineRole = "quarantine_role_id"
 
 const defaultTimeoutRoleName = "Shadow Realm"
+const timeoutModeRole = "role"
+const timeoutModeNative = "native"
+const timeoutModeBoth = "both"
+
+// https://discord.com/developers/docs/resources/guild#modify-guild-member
+const nativeTimeoutMaxDuration = 28 * 24 * time.Hour
 const shootCritChance = 0.05
 const shootMisfireChance = 0.2
 const timeoutDurationWhenShot = 4 * time.Minute
@@ -67,10 +156,108 @@ const actionFixedMessageAuthorTTL = 7 * 24 * time.Hour
 const actionTypeMessage = "MESSAGE"
 const actionTypeReminder = "REMINDER"
 const actionTypeRemoveRole = "REMOVE_ROLE"
+const actionTypeAddRole = "ADD_ROLE"
 const actionTypeFixedMessageAuthor = "FIX_MSG_AUTHOR"
+const actionTypeUnban = "UNBAN"
+const actionTypeUnlock = "UNLOCK"
 const targetTypeUser = "USER"
 const targetTypeChannel = "CHANNEL"
 const targetTypeMessage = "MESSAGE"
+const targetTypeGuild = "GUILD"
+
+const warningsPageSize = 10
+const warningFilterActive = "active"
+const warningFilterExpired = "expired"
+const warningFilterAll = "all"
+const warningAuditCreate = "CREATE"
+const warningAuditPardon = "PARDON"
+const warningAuditEdit = "EDIT"
+const warningAuditRemove = "REMOVE"
+
+const logCategoryMessages = "messages"
+const logCategoryModeration = "moderation"
+const logCategoryMembers = "members"
+const logCategoryRoles = "roles"
+const logCategoryProfiles = "profiles"
+const logCategoryChannels = "channels"
+
+var logCategories = []string{logCategoryMessages, logCategoryModeration, logCategoryMembers, logCategoryRoles, logCategoryProfiles, logCategoryChannels}
+
+// these categories are sent to the message_logs channel unless configured otherwise
+var logCategoriesEnabledByDefault = []string{logCategoryMessages, logCategoryModeration}
+
+const logIgnoreMaxPerList = 50
+const logRedactMaxPatterns = 20
+const logRedactPatternMaxLength = 200
+const logRedactedText = "[redacted]"
+
+const modCasesPageSize = 10
+const caseActionWarn = "warn"
+const caseActionWarnPardon = "warn pardon"
+const caseActionWarnRemove = "warn removal"
+const caseActionShadowRealm = "shadow realm"
+const caseActionShadowRealmEnd = "shadow realm end"
+const caseActionShadowRealmRelease = "shadow realm release"
+const caseActionKick = "kick"
+const caseActionBan = "ban"
+const caseActionUnban = "unban"
+const caseActionPurge = "purge"
+const caseActionQuarantine = "quarantine"
+const caseSourceCommand = "command"
+const caseSourceWarnPolicy = "warning policy"
+const caseSourceScheduler = "scheduler"
+const caseSourceShot = "shot"
+const caseSourceMisfire = "misfire"
+const caseSourceSnipe = "snipe"
+const caseSourceNuke = "nuke"
+const caseSourceMine = "mine"
+const caseSourceDon = "don"
+const caseSourceRejoin = "rejoin"
+const caseSourceAutomod = "automod"
+const caseSourceAntiSpam = "anti-spam"
+const caseSourceLockdown = "lockdown"
+const caseSourceAccountAge = "account age"
+
+const warnPoliciesMaxPerGuild = 10
+const warnPolicyActionTimeout = "timeout"
+const warnPolicyActionKick = "kick"
+const warnPolicyActionBan = "ban"
+
+const automodMaxRulesPerGuild = 25
+const automodPatternMaxLength = 1000
+const automodMatchWords = "words"
+const automodMatchRegex = "regex"
+const automodMatchInvites = "invites"
+const automodMatchDomains = "domains"
+const automodMatchCaps = "caps"
+const automodMatchEmoji = "emoji"
+const automodActionLog = "log"
+const automodActionDelete = "delete"
+const automodActionWarn = "warn"
+const automodActionTimeout = "timeout"
+const automodDefaultCapsPercent = 70
+const automodCapsMinLetters = 10
+const automodDefaultEmojiCount = 10
+const automodDefaultTimeout = 10 * time.Minute
+
+// from the least to the most severe, when several rules match only the most severe one is applied
+var automodActions = []string{automodActionLog, automodActionDelete, automodActionWarn, automodActionTimeout}
+
+const raidDefaultJoinWindow = 30 * time.Second
+const raidMaxJoinWindow = 10 * time.Minute
+const raidAlertCooldown = 10 * time.Minute
+const raidQuarantineDuration = nativeTimeoutMaxDuration // indefinite lockdowns release them on unlock
+const welcomeMessageMaxLength = 1000
+const autoRolesMax = 10
+const autoRoleMaxDelay = 7 * 24 * time.Hour
+const lockdownMaxChannels = 50
+
+const pruneSpamTrackerCRON = "*/10 * * * *"
+const antiSpamActionLog = "log"
+const antiSpamActionDelete = "delete"
+const antiSpamActionTimeout = "timeout"
+const antiSpamMaxWindow = 5 * time.Minute
+const antiSpamMaxTrackedMessages = 50
 
 const minesMaxSetsPerGuild = 10
 const minesMaxAmount = 100
@@ -84,6 +271,31 @@ const minesMaxTriggerTextLength = 60
 const minesNukeChance = 0.006
 const minesNukeResponse = "https://tenor.com/e7oFJluWQlO.gif"
 
+const proposalStatusPending = "PENDING"
+const proposalStatusApproved = "APPROVED"
+const proposalStatusRejected = "REJECTED"
+
+const roleMenuStyleButtons = "buttons"
+const roleMenuStyleSelect = "select"
+const roleMenuDefaultTitle = "Pick your roles!"
+const roleMenuTitleMaxLength = 1000
+
+// https://discord.com/developers/docs/interactions/message-components#select-menu-object
+const roleMenuMaxRoles = 25
+
+// discord allows up to 20 different reactions per message
+const react4RolesMaxPairs = 20
+
+const roleGroupModeUnique = "unique"
+const roleGroupModeLimit = "limit"
+const roleGroupModeVerify = "verify"
+const roleGroupModeDrop = "drop"
+
+const modmailStatusOpen = "OPEN"
+const modmailStatusClosed = "CLOSED"
+const modmailThreadArchiveMinutes = 7 * 24 * 60
+const modmailTranscriptMaxMessages = 5000
+
 const interactionDataOriginalMessageId = 1
 const interactionDataZzzScrapsObj = 100
 const interactionDataZzzRoomIndex = 101
@@ -101,10 +313,12 @@ const parametricReminderMessage = "Remember to use the Parametric Transformer!\n
 const playStoreReminderCRON = "0 * * * *"
 const playStoreReminderMessage = "Remember to get the weekly Play Store prize!\nI will remind you again in 7 days.\nUse !playstorestop if you want to stop these reminders."
 const react4RolesCRON = "0 0 * * 6"
+const roleMenusCRON = "0 0 * * 6"
 
 // Messages
 const userMustBeAdminMessage = "Only the bot's admin can do that"
 const userMustBeModMessage = "Only a mod can do that"
+const userMustBeHelperMessage = "Only a mod or a helper can do that"
 const notAGuildMessage = "This command can only be used on a server"
 const commandReceivedMessage = "Gotcha!"
 const commandSuccessMessage = "Successfully donette!"
@@ -41,10 +41,26 @@ func createTables(db *sqlx.DB) {
 	createTableCommandStats(db)
 	createTableSpammableChannel(db)
 	createTableUserWarning(db)
+	createTableUserWarningAudit(db)
 	createTableReact4RoleMessage(db)
 	createTableServerProperties(db)
 	createTableScheduledActions(db)
 	createTableMines(db)
+	createTableCommandProposal(db)
+	createTableSimpleCommandSettings(db)
+	createTableCommandCollection(db)
+	createTableCommandCollectionEntry(db)
+	createTableWarnPolicy(db)
+	createTableModCase(db)
+	createTableMessageCache(db)
+	createTableArchivedAttachment(db)
+	createTableAutomodRule(db)
+	createTableAntiSpamSettings(db)
+	createTableLockdownOverwrite(db)
+	createTableLockdownQuarantine(db)
+	createTableModmailThread(db)
+	createTableModNote(db)
+	createTableRoleMenuEntry(db)
 }
 
 func createTableDailyCheckInReminder(db *sqlx.DB) {
@@ -82,6 +98,7 @@ func createTableSimpleCommand(db *sqlx.DB) {
 		"UNIQUE(Key, GuildID)",
 	}, db)
 	createIndex("SimpleCommand", "Key", db)
+	addColumn("SimpleCommand", "Embed", "TEXT NOT NULL DEFAULT ''", db)
 }
 
 func createTableCommandStats(db *sqlx.DB) {
@@ -110,6 +127,168 @@ func createTableUserWarning(db *sqlx.DB) {
 		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
 	}, db)
 	createIndex("UserWarning", "DiscordUserID", db)
+	addColumn("UserWarning", "Severity", "INTEGER NOT NULL DEFAULT 1", db)
+	addColumn("UserWarning", "ExpiresAt", "TIMESTAMP", db)
+	addColumn("UserWarning", "PardonedAt", "TIMESTAMP", db)
+	addColumn("UserWarning", "PardonedByID", "VARCHAR(20)", db)
+	createIndex("UserWarning", "GuildID", db)
+}
+
+func createTableUserWarningAudit(db *sqlx.DB) {
+	createTable("UserWarningAudit", []string{
+		"UserWarningID INTEGER NOT NULL",
+		"GuildID VARCHAR(20) NOT NULL",
+		"ActorID VARCHAR(20) NOT NULL",
+		"Action TEXT NOT NULL",
+		"Details TEXT NOT NULL DEFAULT ''",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+	createIndex("UserWarningAudit", "UserWarningID", db)
+}
+
+func createTableModCase(db *sqlx.DB) {
+	createTable("ModCase", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"CaseNumber INTEGER NOT NULL",
+		"ActorID VARCHAR(20) NOT NULL",
+		"TargetID VARCHAR(20) NOT NULL",
+		"Action TEXT NOT NULL",
+		"Reason TEXT NOT NULL DEFAULT ''",
+		"DurationSeconds INTEGER NOT NULL DEFAULT 0",
+		"Source TEXT NOT NULL DEFAULT ''",
+		"UpdatedByID VARCHAR(20)",
+		"UpdatedAt TIMESTAMP",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(GuildID, CaseNumber)",
+	}, db)
+	createIndex("ModCase", "TargetID", db)
+}
+
+func createTableMessageCache(db *sqlx.DB) {
+	createTable("MessageCache", []string{
+		"MessageID VARCHAR(20) UNIQUE NOT NULL",
+		"GuildID VARCHAR(20) NOT NULL",
+		"ChannelID VARCHAR(20) NOT NULL",
+		"AuthorID VARCHAR(20) NOT NULL",
+		"AuthorName TEXT NOT NULL",
+		"AuthorAvatar TEXT NOT NULL DEFAULT ''",
+		"Content TEXT NOT NULL",
+		"Attachments TEXT NOT NULL DEFAULT '[]'",
+		"SentAt TIMESTAMP NOT NULL",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+	createIndex("MessageCache", "GuildID", db)
+	createIndex("MessageCache", "SentAt", db)
+}
+
+func createTableArchivedAttachment(db *sqlx.DB) {
+	createTable("ArchivedAttachment", []string{
+		"MessageID VARCHAR(20) NOT NULL",
+		"AttachmentID VARCHAR(20) UNIQUE NOT NULL",
+		"GuildID VARCHAR(20) NOT NULL",
+		"ChannelID VARCHAR(20) NOT NULL",
+		"Filename TEXT NOT NULL",
+		"ContentType TEXT NOT NULL DEFAULT ''",
+		"Path TEXT NOT NULL",
+		"Size INTEGER NOT NULL",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+	createIndex("ArchivedAttachment", "MessageID", db)
+	createIndex("ArchivedAttachment", "GuildID", db)
+	createIndex("ArchivedAttachment", "CreatedAt", db)
+}
+
+func createTableAutomodRule(db *sqlx.DB) {
+	createTable("AutomodRule", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"MatchType TEXT NOT NULL",
+		"Pattern TEXT NOT NULL DEFAULT ''",
+		"Threshold INTEGER NOT NULL DEFAULT 0",
+		"Action TEXT NOT NULL",
+		"DurationSeconds INTEGER NOT NULL DEFAULT 0",
+		"ExemptChannelIDs TEXT NOT NULL DEFAULT ''",
+		"ExemptRoleIDs TEXT NOT NULL DEFAULT ''",
+		"CreatedByID VARCHAR(20) NOT NULL",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+	createIndex("AutomodRule", "GuildID", db)
+}
+
+func createTableAntiSpamSettings(db *sqlx.DB) {
+	createTable("AntiSpamSettings", []string{
+		"GuildID VARCHAR(20) UNIQUE NOT NULL",
+		"Enabled BOOLEAN NOT NULL DEFAULT 0",
+		"WindowSeconds INTEGER NOT NULL",
+		"MaxMessages INTEGER NOT NULL DEFAULT 0",
+		"MaxDuplicates INTEGER NOT NULL DEFAULT 0",
+		"MaxMentions INTEGER NOT NULL DEFAULT 0",
+		"MaxRepeatedLinks INTEGER NOT NULL DEFAULT 0",
+		"Action TEXT NOT NULL",
+		"TimeoutSeconds INTEGER NOT NULL DEFAULT 0",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+}
+
+func createTableLockdownOverwrite(db *sqlx.DB) {
+	createTable("LockdownOverwrite", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"ChannelID VARCHAR(20) NOT NULL",
+		"HadOverwrite BOOLEAN NOT NULL",
+		"Allow INTEGER NOT NULL DEFAULT 0",
+		"Deny INTEGER NOT NULL DEFAULT 0",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(GuildID, ChannelID)",
+	}, db)
+	createIndex("LockdownOverwrite", "GuildID", db)
+}
+
+func createTableLockdownQuarantine(db *sqlx.DB) {
+	createTable("LockdownQuarantine", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"UserID VARCHAR(20) NOT NULL",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(GuildID, UserID)",
+	}, db)
+	createIndex("LockdownQuarantine", "GuildID", db)
+}
+
+func createTableModmailThread(db *sqlx.DB) {
+	createTable("ModmailThread", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"UserID VARCHAR(20) NOT NULL",
+		"ThreadID VARCHAR(20) NOT NULL",
+		"Status TEXT NOT NULL DEFAULT 'OPEN'",
+		"ClosedByID VARCHAR(20)",
+		"Transcript TEXT",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"ClosedAt TIMESTAMP",
+	}, db)
+	createIndex("ModmailThread", "UserID", db)
+	createIndex("ModmailThread", "ThreadID", db)
+}
+
+func createTableModNote(db *sqlx.DB) {
+	createTable("ModNote", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"UserID VARCHAR(20) NOT NULL",
+		"AuthorID VARCHAR(20) NOT NULL",
+		"Content TEXT NOT NULL",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+	createIndex("ModNote", "UserID", db)
+}
+
+func createTableWarnPolicy(db *sqlx.DB) {
+	createTable("WarnPolicy", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"WarnCount INTEGER NOT NULL CHECK (WarnCount > 0)",
+		"WindowSeconds INTEGER NOT NULL CHECK (WindowSeconds > 0)",
+		"Action TEXT NOT NULL",
+		"DurationSeconds INTEGER NOT NULL DEFAULT 0",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(GuildID, WarnCount)",
+	}, db)
+	createIndex("WarnPolicy", "GuildID", db)
 }
 
 func createTableReact4RoleMessage(db *sqlx.DB) {
@@ -123,6 +302,26 @@ func createTableReact4RoleMessage(db *sqlx.DB) {
 		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
 	}, db)
 	createIndex("React4RoleMessage", "MessageID", db)
+	addColumn("React4RoleMessage", "GroupMode", "VARCHAR(16) NOT NULL DEFAULT ''", db)
+	addColumn("React4RoleMessage", "GroupLimit", "INTEGER NOT NULL DEFAULT 0", db)
+}
+
+func createTableRoleMenuEntry(db *sqlx.DB) {
+	createTable("RoleMenuEntry", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"ChannelID VARCHAR(20) NOT NULL",
+		"MessageID VARCHAR(20) NOT NULL",
+		"Style VARCHAR(16) NOT NULL",
+		"RoleID VARCHAR(20) NOT NULL",
+		"RequiredRoleID VARCHAR(20) NOT NULL DEFAULT ''",
+		"EmojiID VARCHAR(20) NOT NULL DEFAULT ''",
+		"EmojiName VARCHAR(32) NOT NULL DEFAULT ''",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(MessageID, RoleID)",
+	}, db)
+	createIndex("RoleMenuEntry", "MessageID", db)
+	addColumn("RoleMenuEntry", "GroupMode", "VARCHAR(16) NOT NULL DEFAULT ''", db)
+	addColumn("RoleMenuEntry", "GroupLimit", "INTEGER NOT NULL DEFAULT 0", db)
 }
 
 func createTableServerProperties(db *sqlx.DB) {
@@ -163,6 +362,59 @@ func createTableMines(db *sqlx.DB) {
 	createIndex("Mines", "ChannelID", db)
 }
 
+func createTableCommandProposal(db *sqlx.DB) {
+	createTable("CommandProposal", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"Key VARCHAR(36) NOT NULL COLLATE NOCASE",
+		"Response TEXT NOT NULL",
+		"ProposedBy VARCHAR(20) NOT NULL",
+		"Status TEXT NOT NULL DEFAULT 'PENDING'",
+		"ReviewedBy VARCHAR(20)",
+		"ReviewChannelID VARCHAR(20)",
+		"ReviewMessageID VARCHAR(20)",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	}, db)
+	createIndex("CommandProposal", "GuildID", db)
+	createIndex("CommandProposal", "ProposedBy", db)
+}
+
+func createTableSimpleCommandSettings(db *sqlx.DB) {
+	createTable("SimpleCommandSettings", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"Key VARCHAR(36) NOT NULL COLLATE NOCASE",
+		"AllowedChannelIDs TEXT NOT NULL DEFAULT ''",
+		"AllowedRoleIDs TEXT NOT NULL DEFAULT ''",
+		"CooldownSeconds INTEGER NOT NULL DEFAULT 0",
+		"CooldownScope TEXT NOT NULL DEFAULT 'channel'",
+		"NSFW BOOLEAN NOT NULL DEFAULT 0",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(GuildID, Key)",
+	}, db)
+	createIndex("SimpleCommandSettings", "GuildID", db)
+}
+
+func createTableCommandCollection(db *sqlx.DB) {
+	createTable("CommandCollection", []string{
+		"GuildID VARCHAR(20) NOT NULL",
+		"Name VARCHAR(32) NOT NULL COLLATE NOCASE",
+		"CreatedBy VARCHAR(20)",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(GuildID, Name)",
+	}, db)
+	createIndex("CommandCollection", "GuildID", db)
+}
+
+func createTableCommandCollectionEntry(db *sqlx.DB) {
+	createTable("CommandCollectionEntry", []string{
+		"CollectionID INTEGER NOT NULL",
+		"CommandKey VARCHAR(36) NOT NULL COLLATE NOCASE",
+		"Weight INTEGER NOT NULL DEFAULT 1 CHECK (Weight > 0)",
+		"CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"UNIQUE(CollectionID, CommandKey)",
+	}, db)
+	createIndex("CommandCollectionEntry", "CollectionID", db)
+}
+
 // commands
 
 type commandDataStore struct {
@@ -187,6 +439,37 @@ func (c commandDataStore) addSimpleCommand(key, response, guildID, creatorUserID
 	return err
 }
 
+type SimpleCommand struct {
+	Key      string `db:"Key"`
+	Response string `db:"Response"`
+	Embed    string `db:"Embed"`
+}
+
+func (c commandDataStore) addEmbedCommand(key, embedJSON, guildID, creatorUserID string) error {
+	_, err := c.db.Exec(`INSERT INTO SimpleCommand (Key, Response, Embed, GuildID, CreatedBy) VALUES (?, '', ?, ?, ?)`,
+		key, embedJSON, guildID, creatorUserID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+			return errDuplicateCommand
+		}
+	}
+	return err
+}
+
+func (c commandDataStore) setSimpleCommandEmbed(key, embedJSON, guildID string) error {
+	res, err := c.db.Exec(`UPDATE SimpleCommand SET Embed = ? WHERE Key = ? AND GuildID = ?`,
+		embedJSON, key, guildID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return nil
+}
+
 func (c commandDataStore) removeSimpleCommand(key, guildID string) error {
 	res, err := c.db.Exec(`DELETE FROM SimpleCommand WHERE Key = ? AND GuildID = ?`,
 		key, guildID)
@@ -200,6 +483,16 @@ func (c commandDataStore) removeSimpleCommand(key, guildID string) error {
 	return nil
 }
 
+func (c commandDataStore) simpleCommandExists(key, guildID string) (bool, error) {
+	var exists []uint8
+	err := c.db.Select(&exists, `
+		SELECT 1 FROM SimpleCommand
+		WHERE Key = ? AND (GuildID = ? OR GuildID = '') COLLATE NOCASE
+		LIMIT 1`,
+		key, guildID)
+	return len(exists) != 0, err
+}
+
 func (c commandDataStore) getCommandCreator(key, guildID string) (string, error) {
 	var creator string
 	err := c.db.Get(&creator, `SELECT CreatedBy FROM SimpleCommand WHERE Key = ? AND (GuildID = ?) COLLATE NOCASE`,
@@ -207,18 +500,26 @@ func (c commandDataStore) getCommandCreator(key, guildID string) (string, error)
 	return creator, err
 }
 
-func (c commandDataStore) simpleCommandResponse(key, guildID string) (string, error) {
-	var response []string
-	err := c.db.Select(&response, `
-		SELECT Response FROM SimpleCommand
+// simpleCommand prioritizes the global command over the guild's one with the same key
+func (c commandDataStore) simpleCommand(key, guildID string) (SimpleCommand, error) {
+	var cmds []SimpleCommand
+	err := c.db.Select(&cmds, `
+		SELECT Key, Response, Embed FROM SimpleCommand
 		WHERE Key = ? AND (GuildID = ? OR GuildID = '') COLLATE NOCASE
 		ORDER BY CASE WHEN GuildID = '' THEN 0 ELSE 1 END
 		LIMIT 1`,
 		key, guildID)
-	if len(response) == 0 {
-		return "", err
+	if len(cmds) == 0 {
+		return SimpleCommand{}, err
 	}
-	return response[0], err
+	return cmds[0], err
+}
+
+func (c commandDataStore) guildSimpleCommand(key, guildID string) (SimpleCommand, error) {
+	var cmd SimpleCommand
+	err := c.db.Get(&cmd, `SELECT Key, Response, Embed FROM SimpleCommand WHERE Key = ? AND GuildID = ?`,
+		key, guildID)
+	return cmd, err
 }
 
 // Picks a random command, using * as % in the sql query
@@ -304,6 +605,238 @@ func (c commandDataStore) isChannelSpammable(channelID string) (bool, error) {
 	return true, err
 }
 
+// simple command settings
+
+type SimpleCommandSettings struct {
+	ID                int    `db:"SimpleCommandSettings"`
+	GuildID           string `db:"GuildID"`
+	Key               string `db:"Key"`
+	AllowedChannelIDs string `db:"AllowedChannelIDs"`
+	AllowedRoleIDs    string `db:"AllowedRoleIDs"`
+	CooldownSeconds   int    `db:"CooldownSeconds"`
+	CooldownScope     string `db:"CooldownScope"`
+	NSFW              bool   `db:"NSFW"`
+}
+
+func (s SimpleCommandSettings) AllowedChannels() []string {
+	return splitNonEmpty(s.AllowedChannelIDs, serverPropListSeparator)
+}
+
+func (s SimpleCommandSettings) AllowedRoles() []string {
+	return splitNonEmpty(s.AllowedRoleIDs, serverPropListSeparator)
+}
+
+func (s SimpleCommandSettings) IsRestricted() bool {
+	return s.AllowedChannelIDs != "" || s.AllowedRoleIDs != "" || s.CooldownSeconds > 0 || s.NSFW
+}
+
+func (c commandDataStore) setSimpleCommandSettings(settings SimpleCommandSettings) error {
+	_, err := c.db.Exec(`
+		INSERT INTO SimpleCommandSettings (GuildID, Key, AllowedChannelIDs, AllowedRoleIDs, CooldownSeconds, CooldownScope, NSFW)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(GuildID, Key)
+		DO UPDATE SET AllowedChannelIDs = excluded.AllowedChannelIDs, AllowedRoleIDs = excluded.AllowedRoleIDs,
+		              CooldownSeconds = excluded.CooldownSeconds, CooldownScope = excluded.CooldownScope, NSFW = excluded.NSFW`,
+		settings.GuildID, settings.Key, settings.AllowedChannelIDs, settings.AllowedRoleIDs,
+		settings.CooldownSeconds, settings.CooldownScope, settings.NSFW)
+	return err
+}
+
+// simpleCommandSettings returns the default (unrestricted) settings if the command has none stored
+func (c commandDataStore) simpleCommandSettings(key, guildID string) (SimpleCommandSettings, error) {
+	var settings []SimpleCommandSettings
+	err := c.db.Select(&settings, `
+		SELECT SimpleCommandSettings, GuildID, Key, AllowedChannelIDs, AllowedRoleIDs, CooldownSeconds, CooldownScope, NSFW
+		FROM SimpleCommandSettings
+		WHERE Key = ? AND GuildID = ?`,
+		key, guildID)
+	if len(settings) == 0 {
+		return SimpleCommandSettings{GuildID: guildID, Key: key, CooldownScope: cooldownScopeChannel}, err
+	}
+	return settings[0], err
+}
+
+func (c commandDataStore) removeSimpleCommandSettings(key, guildID string) error {
+	_, err := c.db.Exec(`DELETE FROM SimpleCommandSettings WHERE Key = ? AND GuildID = ?`, key, guildID)
+	return err
+}
+
+// command collections
+
+type CommandCollection struct {
+	ID         int    `db:"CommandCollection"`
+	GuildID    string `db:"GuildID"`
+	Name       string `db:"Name"`
+	EntryCount int    `db:"EntryCount"`
+}
+
+type CommandCollectionEntry struct {
+	CommandKey string `db:"CommandKey"`
+	Weight     int    `db:"Weight"`
+}
+
+var errDuplicateCollection = errors.New("a collection with the same name already exists in this server")
+
+func (c commandDataStore) addCommandCollection(guildID, name, creatorUserID string) error {
+	_, err := c.db.Exec(`INSERT INTO CommandCollection (GuildID, Name, CreatedBy) VALUES (?, ?, ?)`,
+		guildID, name, creatorUserID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+			return errDuplicateCollection
+		}
+	}
+	return err
+}
+
+func (c commandDataStore) removeCommandCollection(guildID, name string) error {
+	collection, err := c.commandCollection(guildID, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(`DELETE FROM CommandCollectionEntry WHERE CollectionID = ?`, collection.ID)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(`DELETE FROM CommandCollection WHERE CommandCollection = ?`, collection.ID)
+	return err
+}
+
+func (c commandDataStore) commandCollection(guildID, name string) (CommandCollection, error) {
+	var collection CommandCollection
+	err := c.db.Get(&collection, `
+		SELECT CommandCollection, GuildID, Name,
+		       (SELECT COUNT(*) FROM CommandCollectionEntry WHERE CollectionID = CommandCollection) AS EntryCount
+		FROM CommandCollection
+		WHERE GuildID = ? AND Name = ?`,
+		guildID, name)
+	return collection, err
+}
+
+func (c commandDataStore) guildCommandCollections(guildID string) ([]CommandCollection, error) {
+	var collections []CommandCollection
+	err := c.db.Select(&collections, `
+		SELECT CommandCollection, GuildID, Name,
+		       (SELECT COUNT(*) FROM CommandCollectionEntry WHERE CollectionID = CommandCollection) AS EntryCount
+		FROM CommandCollection
+		WHERE GuildID = ?
+		ORDER BY Name`,
+		guildID)
+	return collections, err
+}
+
+// tagCommand also updates the weight if the command was already tagged
+func (c commandDataStore) tagCommand(collectionID int, commandKey string, weight int) error {
+	_, err := c.db.Exec(`
+		INSERT INTO CommandCollectionEntry (CollectionID, CommandKey, Weight)
+		VALUES (?, ?, ?)
+		ON CONFLICT(CollectionID, CommandKey)
+		DO UPDATE SET Weight = excluded.Weight`,
+		collectionID, commandKey, weight)
+	return err
+}
+
+func (c commandDataStore) untagCommand(collectionID int, commandKey string) error {
+	res, err := c.db.Exec(`DELETE FROM CommandCollectionEntry WHERE CollectionID = ? AND CommandKey = ?`,
+		collectionID, commandKey)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return nil
+}
+
+func (c commandDataStore) untagCommandFromGuildCollections(commandKey, guildID string) error {
+	_, err := c.db.Exec(`
+		DELETE FROM CommandCollectionEntry
+		WHERE CommandKey = ? AND CollectionID IN (SELECT CommandCollection FROM CommandCollection WHERE GuildID = ?)`,
+		commandKey, guildID)
+	return err
+}
+
+func (c commandDataStore) commandCollectionEntries(collectionID int) ([]CommandCollectionEntry, error) {
+	var entries []CommandCollectionEntry
+	err := c.db.Select(&entries, `
+		SELECT CommandKey, Weight FROM CommandCollectionEntry
+		WHERE CollectionID = ?
+		ORDER BY CommandKey`,
+		collectionID)
+	return entries, err
+}
+
+// command proposals
+
+type CommandProposal struct {
+	ID              int       `db:"CommandProposal"`
+	GuildID         string    `db:"GuildID"`
+	Key             string    `db:"Key"`
+	Response        string    `db:"Response"`
+	ProposedBy      string    `db:"ProposedBy"`
+	Status          string    `db:"Status"`
+	ReviewedBy      string    `db:"ReviewedBy"`
+	ReviewChannelID string    `db:"ReviewChannelID"`
+	ReviewMessageID string    `db:"ReviewMessageID"`
+	CreatedAt       time.Time `db:"CreatedAt"`
+}
+
+func (c commandDataStore) addCommandProposal(guildID, key, response, proposedBy string) (int, error) {
+	res, err := c.db.Exec(`INSERT INTO CommandProposal (GuildID, Key, Response, ProposedBy, Status) VALUES (?, ?, ?, ?, ?)`,
+		guildID, key, response, proposedBy, proposalStatusPending)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (c commandDataStore) setCommandProposalReviewMessage(id int, channelID, messageID string) error {
+	_, err := c.db.Exec(`UPDATE CommandProposal SET ReviewChannelID = ?, ReviewMessageID = ? WHERE CommandProposal = ?`,
+		channelID, messageID, id)
+	return err
+}
+
+func (c commandDataStore) commandProposal(id int) (CommandProposal, error) {
+	var proposal CommandProposal
+	err := c.db.Get(&proposal, `
+		SELECT CommandProposal, GuildID, Key, Response, ProposedBy, Status,
+		       COALESCE(ReviewedBy, '') AS ReviewedBy,
+		       COALESCE(ReviewChannelID, '') AS ReviewChannelID,
+		       COALESCE(ReviewMessageID, '') AS ReviewMessageID,
+		       CreatedAt
+		FROM CommandProposal WHERE CommandProposal = ?`, id)
+	return proposal, err
+}
+
+func (c commandDataStore) countPendingCommandProposals(guildID, userID string) (int, error) {
+	var count int
+	err := c.db.Get(&count, `SELECT COUNT(*) FROM CommandProposal WHERE GuildID = ? AND ProposedBy = ? AND Status = ?`,
+		guildID, userID, proposalStatusPending)
+	return count, err
+}
+
+// reviewCommandProposal only updates pending proposals, so two mods can't review the same proposal twice
+func (c commandDataStore) reviewCommandProposal(id int, status, reviewerID string) error {
+	res, err := c.db.Exec(`UPDATE CommandProposal SET Status = ?, ReviewedBy = ? WHERE CommandProposal = ? AND Status = ?`,
+		status, reviewerID, id, proposalStatusPending)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return err
+}
+
+func (c commandDataStore) resetCommandProposal(id int) error {
+	_, err := c.db.Exec(`UPDATE CommandProposal SET Status = ?, ReviewedBy = NULL WHERE CommandProposal = ?`,
+		proposalStatusPending, id)
+	return err
+}
+
 // genshin
 
 type genshinDataStore struct {
@@ -370,23 +903,267 @@ type moddingDataStore struct {
 	db *sqlx.DB
 }
 
-func (s moddingDataStore) warnUser(userID, modID, guildID, reason string) error {
-	_, err := s.db.Exec(`INSERT OR REPLACE INTO UserWarning (DiscordUserID, WarnedByID, GuildID, Reason, CreatedAt) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		userID, modID, guildID, reason)
-	return err
+// activeWarningCondition filters out the expired and pardoned warnings
+const activeWarningCondition = `PardonedAt IS NULL AND (ExpiresAt IS NULL OR ExpiresAt > CURRENT_TIMESTAMP)`
+
+const userWarningColumns = `UserWarning, DiscordUserID, WarnedByID, GuildID, Reason, Severity, ExpiresAt, PardonedAt, PardonedByID, CreatedAt`
+
+// warnUser stores the warning and returns its ID, expiresAt can be nil for warnings that never expire
+func (s moddingDataStore) warnUser(userID, modID, guildID, reason string, severity int, expiresAt *time.Time) (int, error) {
+	var expires any
+	if expiresAt != nil {
+		expires = sqliteTimestamp(*expiresAt)
+	}
+	res, err := s.db.Exec(`INSERT INTO UserWarning (DiscordUserID, WarnedByID, GuildID, Reason, Severity, ExpiresAt, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		userID, modID, guildID, reason, severity, expires)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	err = s.addUserWarningAudit(int(id), guildID, modID, warningAuditCreate, fmt.Sprintf("user: %s, severity: %d, reason: %s", userID, severity, reason))
+	return int(id), err
+}
+
+func (s moddingDataStore) userWarning(id int, guildID string) (UserWarning, error) {
+	var warning UserWarning
+	err := s.db.Get(&warning, `SELECT `+userWarningColumns+` FROM UserWarning WHERE UserWarning = ? AND GuildID = ?`, id, guildID)
+	return warning, err
 }
 
 func (s moddingDataStore) userWarnings(userID, guildID string) ([]UserWarning, error) {
 	warnings := []UserWarning{}
-	err := s.db.Select(&warnings, `SELECT * FROM UserWarning WHERE DiscordUserID = ? AND GuildID = ?`,
+	err := s.db.Select(&warnings, `SELECT `+userWarningColumns+` FROM UserWarning WHERE DiscordUserID = ? AND GuildID = ? ORDER BY CreatedAt DESC`,
+		userID, guildID)
+	return warnings, err
+}
+
+// filteredUserWarnings returns a page of the user's warnings, and the total amount of warnings matching the filter
+func (s moddingDataStore) filteredUserWarnings(userID, guildID, filter string, page, pageSize int) ([]UserWarning, int, error) {
+	condition := "1 = 1"
+	switch filter {
+	case warningFilterActive:
+		condition = activeWarningCondition
+	case warningFilterExpired:
+		condition = "NOT (" + activeWarningCondition + ")"
+	}
+
+	var total int
+	err := s.db.Get(&total, `SELECT COUNT(*) FROM UserWarning WHERE DiscordUserID = ? AND GuildID = ? AND `+condition,
 		userID, guildID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	warnings := []UserWarning{}
+	err = s.db.Select(&warnings, `SELECT `+userWarningColumns+` FROM UserWarning WHERE DiscordUserID = ? AND GuildID = ? AND `+condition+`
+		ORDER BY CreatedAt DESC, UserWarning DESC LIMIT ? OFFSET ?`,
+		userID, guildID, pageSize, page*pageSize)
+	return warnings, total, err
+}
+
+func (s moddingDataStore) guildWarnings(guildID string) ([]UserWarning, error) {
+	warnings := []UserWarning{}
+	err := s.db.Select(&warnings, `SELECT `+userWarningColumns+` FROM UserWarning WHERE GuildID = ? ORDER BY UserWarning`, guildID)
 	return warnings, err
 }
 
+// countUserWarningsSince only counts the active warnings
+func (s moddingDataStore) countUserWarningsSince(userID, guildID string, since time.Time) (int, error) {
+	var count int
+	err := s.db.Get(&count, `SELECT COUNT(*) FROM UserWarning WHERE DiscordUserID = ? AND GuildID = ? AND CreatedAt >= ? AND `+activeWarningCondition,
+		userID, guildID, sqliteTimestamp(since))
+	return count, err
+}
+
+func (s moddingDataStore) pardonUserWarning(id int, guildID, modID string) error {
+	res, err := s.db.Exec(`UPDATE UserWarning SET PardonedAt = CURRENT_TIMESTAMP, PardonedByID = ? WHERE UserWarning = ? AND GuildID = ? AND PardonedAt IS NULL`,
+		modID, id, guildID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return s.addUserWarningAudit(id, guildID, modID, warningAuditPardon, "")
+}
+
+func (s moddingDataStore) editUserWarningReason(id int, guildID, modID, reason string) error {
+	previous, err := s.userWarning(id, guildID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE UserWarning SET Reason = ? WHERE UserWarning = ? AND GuildID = ?`, reason, id, guildID)
+	if err != nil {
+		return err
+	}
+	return s.addUserWarningAudit(id, guildID, modID, warningAuditEdit, fmt.Sprintf("from: %s\nto: %s", previous.Reason, reason))
+}
+
+func (s moddingDataStore) removeUserWarning(id int, guildID, modID string) error {
+	previous, err := s.userWarning(id, guildID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`DELETE FROM UserWarning WHERE UserWarning = ? AND GuildID = ?`, id, guildID)
+	if err != nil {
+		return err
+	}
+	return s.addUserWarningAudit(id, guildID, modID, warningAuditRemove,
+		fmt.Sprintf("user: %s, warned by: %s, severity: %d, reason: %s", previous.UserID, previous.WarnedByID, previous.Severity, previous.Reason))
+}
+
+type UserWarningAudit struct {
+	ID            int       `db:"UserWarningAudit"`
+	UserWarningID int       `db:"UserWarningID"`
+	GuildID       string    `db:"GuildID"`
+	ActorID       string    `db:"ActorID"`
+	Action        string    `db:"Action"`
+	Details       string    `db:"Details"`
+	CreatedAt     time.Time `db:"CreatedAt"`
+}
+
+func (s moddingDataStore) addUserWarningAudit(warningID int, guildID, actorID, action, details string) error {
+	_, err := s.db.Exec(`INSERT INTO UserWarningAudit (UserWarningID, GuildID, ActorID, Action, Details) VALUES (?, ?, ?, ?, ?)`,
+		warningID, guildID, actorID, action, details)
+	return err
+}
+
+func (s moddingDataStore) userWarningAudits(warningID int, guildID string) ([]UserWarningAudit, error) {
+	audits := []UserWarningAudit{}
+	err := s.db.Select(&audits, `SELECT * FROM UserWarningAudit WHERE UserWarningID = ? AND GuildID = ? ORDER BY UserWarningAudit`,
+		warningID, guildID)
+	return audits, err
+}
+
+type WarnPolicy struct {
+	ID              int    `db:"WarnPolicy"`
+	GuildID         string `db:"GuildID"`
+	WarnCount       int    `db:"WarnCount"`
+	WindowSeconds   int    `db:"WindowSeconds"`
+	Action          string `db:"Action"`
+	DurationSeconds int    `db:"DurationSeconds"`
+}
+
+func (p WarnPolicy) Window() time.Duration {
+	return time.Duration(p.WindowSeconds) * time.Second
+}
+
+func (p WarnPolicy) Duration() time.Duration {
+	return time.Duration(p.DurationSeconds) * time.Second
+}
+
+// addWarnPolicy replaces the policy with the same warn count, if any
+func (s moddingDataStore) addWarnPolicy(p WarnPolicy) error {
+	_, err := s.db.Exec(`
+		INSERT INTO WarnPolicy (GuildID, WarnCount, WindowSeconds, Action, DurationSeconds)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(GuildID, WarnCount)
+		DO UPDATE SET WindowSeconds = excluded.WindowSeconds, Action = excluded.Action, DurationSeconds = excluded.DurationSeconds`,
+		p.GuildID, p.WarnCount, p.WindowSeconds, p.Action, p.DurationSeconds)
+	return err
+}
+
+// warnPolicies are sorted from the most to the least severe
+func (s moddingDataStore) warnPolicies(guildID string) ([]WarnPolicy, error) {
+	var policies []WarnPolicy
+	err := s.db.Select(&policies, `
+		SELECT WarnPolicy, GuildID, WarnCount, WindowSeconds, Action, DurationSeconds
+		FROM WarnPolicy
+		WHERE GuildID = ?
+		ORDER BY WarnCount DESC`, guildID)
+	return policies, err
+}
+
+func (s moddingDataStore) removeWarnPolicy(id int, guildID string) error {
+	res, err := s.db.Exec(`DELETE FROM WarnPolicy WHERE WarnPolicy = ? AND GuildID = ?`, id, guildID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return nil
+}
+
+type ModCase struct {
+	ID              int            `db:"ModCase"`
+	GuildID         string         `db:"GuildID"`
+	CaseNumber      int            `db:"CaseNumber"`
+	ActorID         string         `db:"ActorID"`
+	TargetID        string         `db:"TargetID"`
+	Action          string         `db:"Action"`
+	Reason          string         `db:"Reason"`
+	DurationSeconds int            `db:"DurationSeconds"`
+	Source          string         `db:"Source"`
+	UpdatedByID     sql.NullString `db:"UpdatedByID"`
+	UpdatedAt       *time.Time     `db:"UpdatedAt"`
+	CreatedAt       time.Time      `db:"CreatedAt"`
+}
+
+func (c ModCase) Duration() time.Duration {
+	return time.Duration(c.DurationSeconds) * time.Second
+}
+
+// addModCase stores the case with the next case number of its guild, and returns that number
+func (s moddingDataStore) addModCase(c ModCase) (int, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO ModCase (GuildID, CaseNumber, ActorID, TargetID, Action, Reason, DurationSeconds, Source)
+		SELECT ?, COALESCE(MAX(CaseNumber), 0) + 1, ?, ?, ?, ?, ?, ?
+		FROM ModCase WHERE GuildID = ?`,
+		c.GuildID, c.ActorID, c.TargetID, c.Action, c.Reason, c.DurationSeconds, c.Source, c.GuildID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	var caseNumber int
+	err = s.db.Get(&caseNumber, `SELECT CaseNumber FROM ModCase WHERE ModCase = ?`, id)
+	return caseNumber, err
+}
+
+func (s moddingDataStore) modCase(guildID string, caseNumber int) (ModCase, error) {
+	var c ModCase
+	err := s.db.Get(&c, `SELECT * FROM ModCase WHERE GuildID = ? AND CaseNumber = ?`, guildID, caseNumber)
+	return c, err
+}
+
+// userModCases returns a page of the cases targeting the user, newest first, and the total amount of cases
+func (s moddingDataStore) userModCases(guildID, userID string, page, pageSize int) ([]ModCase, int, error) {
+	var total int
+	err := s.db.Get(&total, `SELECT COUNT(*) FROM ModCase WHERE GuildID = ? AND TargetID = ?`, guildID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	cases := []ModCase{}
+	err = s.db.Select(&cases, `SELECT * FROM ModCase WHERE GuildID = ? AND TargetID = ? ORDER BY CaseNumber DESC LIMIT ? OFFSET ?`,
+		guildID, userID, pageSize, page*pageSize)
+	return cases, total, err
+}
+
+func (s moddingDataStore) setModCaseReason(guildID string, caseNumber int, reason, modID string) error {
+	res, err := s.db.Exec(`UPDATE ModCase SET Reason = ?, UpdatedByID = ?, UpdatedAt = CURRENT_TIMESTAMP WHERE GuildID = ? AND CaseNumber = ?`,
+		reason, modID, guildID, caseNumber)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return nil
+}
+
 func (s moddingDataStore) addReact4Roles(r4rs []React4RoleMessage) error {
 	for _, r4r := range r4rs {
-		_, err := s.db.Exec(`INSERT OR REPLACE INTO React4RoleMessage (ChannelID, MessageID, EmojiID, EmojiName, RoleID, RequiredRoleID) VALUES (?, ?, ?, ?, ?, ?)`,
-			r4r.ChannelID, r4r.MessageID, r4r.EmojiID, r4r.EmojiName, r4r.RoleID, r4r.RequiredRoleID)
+		_, err := s.db.Exec(`INSERT OR REPLACE INTO React4RoleMessage (ChannelID, MessageID, EmojiID, EmojiName, RoleID, RequiredRoleID, GroupMode, GroupLimit) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			r4r.ChannelID, r4r.MessageID, r4r.EmojiID, r4r.EmojiName, r4r.RoleID, r4r.RequiredRoleID, r4r.GroupMode, r4r.GroupLimit)
 		if err != nil {
 			return err
 		}
@@ -401,6 +1178,12 @@ func (s moddingDataStore) react4Roles(channelID, messageID string) ([]React4Role
 	return r4rs, err
 }
 
+func (s moddingDataStore) react4RolesByMessageID(messageID string) ([]React4RoleMessage, error) {
+	var r4rs []React4RoleMessage
+	err := s.db.Select(&r4rs, `SELECT * FROM React4RoleMessage WHERE MessageID = ?`, messageID)
+	return r4rs, err
+}
+
 func (s moddingDataStore) allReact4Roles() ([]React4RoleMessage, error) {
 	var r4rs []React4RoleMessage
 	err := s.db.Select(&r4rs, `SELECT * FROM React4RoleMessage`)
@@ -408,11 +1191,465 @@ func (s moddingDataStore) allReact4Roles() ([]React4RoleMessage, error) {
 }
 
 func (s moddingDataStore) deleteReact4Roles(channelID, messageID string) error {
-	_, err := s.db.Exec(`DELETE * FROM React4RoleMessage WHERE ChannelID = ? AND MessageID = ?`,
+	_, err := s.db.Exec(`DELETE FROM React4RoleMessage WHERE ChannelID = ? AND MessageID = ?`,
 		channelID, messageID)
 	return err
 }
 
+// replaceReact4Roles swaps the pairs of a message in a single transaction, so a failed insert keeps the old ones
+func (s moddingDataStore) replaceReact4Roles(channelID, messageID string, r4rs []React4RoleMessage) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM React4RoleMessage WHERE ChannelID = ? AND MessageID = ?`, channelID, messageID)
+	if err != nil {
+		return err
+	}
+	for _, r4r := range r4rs {
+		_, err = tx.Exec(`INSERT OR REPLACE INTO React4RoleMessage (ChannelID, MessageID, EmojiID, EmojiName, RoleID, RequiredRoleID, GroupMode, GroupLimit) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			r4r.ChannelID, r4r.MessageID, r4r.EmojiID, r4r.EmojiName, r4r.RoleID, r4r.RequiredRoleID, r4r.GroupMode, r4r.GroupLimit)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type RoleMenuEntry struct {
+	ID             int       `db:"RoleMenuEntry"`
+	GuildID        string    `db:"GuildID"`
+	ChannelID      string    `db:"ChannelID"`
+	MessageID      string    `db:"MessageID"`
+	Style          string    `db:"Style"`
+	RoleID         string    `db:"RoleID"`
+	RequiredRoleID string    `db:"RequiredRoleID"`
+	EmojiID        string    `db:"EmojiID"`
+	EmojiName      string    `db:"EmojiName"`
+	GroupMode      string    `db:"GroupMode"`
+	GroupLimit     int       `db:"GroupLimit"`
+	CreatedAt      time.Time `db:"CreatedAt"`
+}
+
+func (s moddingDataStore) addRoleMenuEntries(entries []RoleMenuEntry) error {
+	for _, e := range entries {
+		_, err := s.db.Exec(`INSERT INTO RoleMenuEntry (GuildID, ChannelID, MessageID, Style, RoleID, RequiredRoleID, EmojiID, EmojiName, GroupMode, GroupLimit) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.GuildID, e.ChannelID, e.MessageID, e.Style, e.RoleID, e.RequiredRoleID, e.EmojiID, e.EmojiName, e.GroupMode, e.GroupLimit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s moddingDataStore) roleMenuEntries(messageID string) ([]RoleMenuEntry, error) {
+	var entries []RoleMenuEntry
+	err := s.db.Select(&entries, `SELECT * FROM RoleMenuEntry WHERE MessageID = ? ORDER BY RoleMenuEntry`, messageID)
+	return entries, err
+}
+
+// allRoleMenus returns one entry per role menu message
+func (s moddingDataStore) allRoleMenus() ([]RoleMenuEntry, error) {
+	var entries []RoleMenuEntry
+	err := s.db.Select(&entries, `SELECT * FROM RoleMenuEntry GROUP BY MessageID`)
+	return entries, err
+}
+
+func (s moddingDataStore) deleteRoleMenu(messageID string) error {
+	_, err := s.db.Exec(`DELETE FROM RoleMenuEntry WHERE MessageID = ?`, messageID)
+	return err
+}
+
+type CachedMessage struct {
+	ID           int       `db:"MessageCache"`
+	MessageID    string    `db:"MessageID"`
+	GuildID      string    `db:"GuildID"`
+	ChannelID    string    `db:"ChannelID"`
+	AuthorID     string    `db:"AuthorID"`
+	AuthorName   string    `db:"AuthorName"`
+	AuthorAvatar string    `db:"AuthorAvatar"`
+	Content      string    `db:"Content"`
+	Attachments  string    `db:"Attachments"`
+	SentAt       time.Time `db:"SentAt"`
+	CreatedAt    time.Time `db:"CreatedAt"`
+}
+
+func (s moddingDataStore) cacheMessage(m CachedMessage) error {
+	_, err := s.db.Exec(`
+		INSERT INTO MessageCache (MessageID, GuildID, ChannelID, AuthorID, AuthorName, AuthorAvatar, Content, Attachments, SentAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(MessageID)
+		DO UPDATE SET Content = excluded.Content, Attachments = excluded.Attachments`,
+		m.MessageID, m.GuildID, m.ChannelID, m.AuthorID, m.AuthorName, m.AuthorAvatar, m.Content, m.Attachments, sqliteTimestamp(m.SentAt))
+	return err
+}
+
+func (s moddingDataStore) cachedMessage(messageID string) (CachedMessage, error) {
+	var m CachedMessage
+	err := s.db.Get(&m, `SELECT * FROM MessageCache WHERE MessageID = ?`, messageID)
+	return m, err
+}
+
+func (s moddingDataStore) removeCachedMessage(messageID string) error {
+	_, err := s.db.Exec(`DELETE FROM MessageCache WHERE MessageID = ?`, messageID)
+	return err
+}
+
+// purgeMessageCache removes the guild's cached messages sent before the given time
+func (s moddingDataStore) purgeMessageCache(guildID string, before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM MessageCache WHERE GuildID = ? AND SentAt < ?`, guildID, sqliteTimestamp(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s moddingDataStore) purgeGuildMessageCache(guildID string) error {
+	_, err := s.db.Exec(`DELETE FROM MessageCache WHERE GuildID = ?`, guildID)
+	return err
+}
+
+// cachedMessageGuildIDs returns the guilds that have any cached messages
+func (s moddingDataStore) cachedMessageGuildIDs() ([]string, error) {
+	var guildIDs []string
+	err := s.db.Select(&guildIDs, `SELECT DISTINCT GuildID FROM MessageCache`)
+	return guildIDs, err
+}
+
+type AutomodRule struct {
+	ID               int       `db:"AutomodRule"`
+	GuildID          string    `db:"GuildID"`
+	MatchType        string    `db:"MatchType"`
+	Pattern          string    `db:"Pattern"`
+	Threshold        int       `db:"Threshold"`
+	Action           string    `db:"Action"`
+	DurationSeconds  int       `db:"DurationSeconds"`
+	ExemptChannelIDs string    `db:"ExemptChannelIDs"`
+	ExemptRoleIDs    string    `db:"ExemptRoleIDs"`
+	CreatedByID      string    `db:"CreatedByID"`
+	CreatedAt        time.Time `db:"CreatedAt"`
+}
+
+func (r AutomodRule) Duration() time.Duration {
+	return time.Duration(r.DurationSeconds) * time.Second
+}
+
+func (s moddingDataStore) addAutomodRule(r AutomodRule) (int, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO AutomodRule (GuildID, MatchType, Pattern, Threshold, Action, DurationSeconds, ExemptChannelIDs, ExemptRoleIDs, CreatedByID)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.GuildID, r.MatchType, r.Pattern, r.Threshold, r.Action, r.DurationSeconds, r.ExemptChannelIDs, r.ExemptRoleIDs, r.CreatedByID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s moddingDataStore) automodRules(guildID string) ([]AutomodRule, error) {
+	var rules []AutomodRule
+	err := s.db.Select(&rules, `SELECT * FROM AutomodRule WHERE GuildID = ? ORDER BY AutomodRule`, guildID)
+	return rules, err
+}
+
+func (s moddingDataStore) removeAutomodRule(id int, guildID string) error {
+	res, err := s.db.Exec(`DELETE FROM AutomodRule WHERE AutomodRule = ? AND GuildID = ?`, id, guildID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return err
+}
+
+type AntiSpamSettings struct {
+	ID               int    `db:"AntiSpamSettings"`
+	GuildID          string `db:"GuildID"`
+	Enabled          bool   `db:"Enabled"`
+	WindowSeconds    int    `db:"WindowSeconds"`
+	MaxMessages      int    `db:"MaxMessages"`
+	MaxDuplicates    int    `db:"MaxDuplicates"`
+	MaxMentions      int    `db:"MaxMentions"`
+	MaxRepeatedLinks int    `db:"MaxRepeatedLinks"`
+	Action           string `db:"Action"`
+	TimeoutSeconds   int    `db:"TimeoutSeconds"`
+}
+
+func (s AntiSpamSettings) Window() time.Duration {
+	return time.Duration(s.WindowSeconds) * time.Second
+}
+
+func (s AntiSpamSettings) Timeout() time.Duration {
+	return time.Duration(s.TimeoutSeconds) * time.Second
+}
+
+func (s moddingDataStore) setAntiSpamSettings(settings AntiSpamSettings) error {
+	_, err := s.db.Exec(`
+		INSERT INTO AntiSpamSettings (GuildID, Enabled, WindowSeconds, MaxMessages, MaxDuplicates, MaxMentions, MaxRepeatedLinks, Action, TimeoutSeconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(GuildID)
+		DO UPDATE SET Enabled = excluded.Enabled, WindowSeconds = excluded.WindowSeconds, MaxMessages = excluded.MaxMessages,
+		              MaxDuplicates = excluded.MaxDuplicates, MaxMentions = excluded.MaxMentions,
+		              MaxRepeatedLinks = excluded.MaxRepeatedLinks, Action = excluded.Action, TimeoutSeconds = excluded.TimeoutSeconds`,
+		settings.GuildID, settings.Enabled, settings.WindowSeconds, settings.MaxMessages, settings.MaxDuplicates,
+		settings.MaxMentions, settings.MaxRepeatedLinks, settings.Action, settings.TimeoutSeconds)
+	return err
+}
+
+// antiSpamSettings returns the default (disabled) settings if the guild has none stored
+func (s moddingDataStore) antiSpamSettings(guildID string) (AntiSpamSettings, error) {
+	var settings []AntiSpamSettings
+	err := s.db.Select(&settings, `
+		SELECT AntiSpamSettings, GuildID, Enabled, WindowSeconds, MaxMessages, MaxDuplicates, MaxMentions, MaxRepeatedLinks, Action, TimeoutSeconds
+		FROM AntiSpamSettings
+		WHERE GuildID = ?`,
+		guildID)
+	if len(settings) == 0 {
+		return defaultAntiSpamSettings(guildID), err
+	}
+	return settings[0], err
+}
+
+// LockdownOverwrite stores the @everyone overwrite a channel had before the lockdown, to restore it on unlock
+type LockdownOverwrite struct {
+	ID           int    `db:"LockdownOverwrite"`
+	GuildID      string `db:"GuildID"`
+	ChannelID    string `db:"ChannelID"`
+	HadOverwrite bool   `db:"HadOverwrite"`
+	Allow        int64  `db:"Allow"`
+	Deny         int64  `db:"Deny"`
+}
+
+// addLockdownOverwrite keeps the first stored overwrite, so locking twice does not lose the original one
+func (s moddingDataStore) addLockdownOverwrite(o LockdownOverwrite) error {
+	_, err := s.db.Exec(`
+		INSERT INTO LockdownOverwrite (GuildID, ChannelID, HadOverwrite, Allow, Deny)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(GuildID, ChannelID) DO NOTHING`,
+		o.GuildID, o.ChannelID, o.HadOverwrite, o.Allow, o.Deny)
+	return err
+}
+
+func (s moddingDataStore) lockdownOverwrites(guildID string) ([]LockdownOverwrite, error) {
+	var overwrites []LockdownOverwrite
+	err := s.db.Select(&overwrites, `
+		SELECT LockdownOverwrite, GuildID, ChannelID, HadOverwrite, Allow, Deny
+		FROM LockdownOverwrite
+		WHERE GuildID = ?`, guildID)
+	return overwrites, err
+}
+
+func (s moddingDataStore) removeLockdownOverwrite(id int) error {
+	_, err := s.db.Exec(`DELETE FROM LockdownOverwrite WHERE LockdownOverwrite = ?`, id)
+	return err
+}
+
+// addLockdownQuarantine remembers a member quarantined for joining during a lockdown, to release them on unlock
+func (s moddingDataStore) addLockdownQuarantine(guildID, userID string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO LockdownQuarantine (GuildID, UserID)
+		VALUES (?, ?)
+		ON CONFLICT(GuildID, UserID) DO NOTHING`,
+		guildID, userID)
+	return err
+}
+
+func (s moddingDataStore) lockdownQuarantinedUserIDs(guildID string) ([]string, error) {
+	var userIDs []string
+	err := s.db.Select(&userIDs, `SELECT UserID FROM LockdownQuarantine WHERE GuildID = ?`, guildID)
+	return userIDs, err
+}
+
+func (s moddingDataStore) removeLockdownQuarantine(guildID, userID string) error {
+	_, err := s.db.Exec(`DELETE FROM LockdownQuarantine WHERE GuildID = ? AND UserID = ?`, guildID, userID)
+	return err
+}
+
+func (s moddingDataStore) isGuildLockedDown(guildID string) (bool, error) {
+	var count int
+	err := s.db.Get(&count, `SELECT COUNT(*) FROM LockdownOverwrite WHERE GuildID = ?`, guildID)
+	return count > 0, err
+}
+
+type ModNote struct {
+	ID        int       `db:"ModNote"`
+	GuildID   string    `db:"GuildID"`
+	UserID    string    `db:"UserID"`
+	AuthorID  string    `db:"AuthorID"`
+	Content   string    `db:"Content"`
+	CreatedAt time.Time `db:"CreatedAt"`
+}
+
+func (s moddingDataStore) addModNote(guildID, userID, authorID, content string) (int, error) {
+	res, err := s.db.Exec(`INSERT INTO ModNote (GuildID, UserID, AuthorID, Content) VALUES (?, ?, ?, ?)`,
+		guildID, userID, authorID, content)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s moddingDataStore) userModNotes(guildID, userID string) ([]ModNote, error) {
+	var notes []ModNote
+	err := s.db.Select(&notes, `SELECT * FROM ModNote WHERE GuildID = ? AND UserID = ? ORDER BY ModNote`, guildID, userID)
+	return notes, err
+}
+
+func (s moddingDataStore) removeModNote(id int, guildID string) error {
+	res, err := s.db.Exec(`DELETE FROM ModNote WHERE ModNote = ? AND GuildID = ?`, id, guildID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return err
+}
+
+type ModmailThread struct {
+	ID         int            `db:"ModmailThread"`
+	GuildID    string         `db:"GuildID"`
+	UserID     string         `db:"UserID"`
+	ThreadID   string         `db:"ThreadID"`
+	Status     string         `db:"Status"`
+	ClosedByID sql.NullString `db:"ClosedByID"`
+	Transcript sql.NullString `db:"Transcript"`
+	CreatedAt  time.Time      `db:"CreatedAt"`
+	ClosedAt   *time.Time     `db:"ClosedAt"`
+}
+
+func (s moddingDataStore) addModmailThread(guildID, userID, threadID string) (int, error) {
+	res, err := s.db.Exec(`INSERT INTO ModmailThread (GuildID, UserID, ThreadID, Status) VALUES (?, ?, ?, ?)`,
+		guildID, userID, threadID, modmailStatusOpen)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// userOpenModmailThreads does not load the transcripts, open threads don't have one yet
+func (s moddingDataStore) userOpenModmailThreads(userID string) ([]ModmailThread, error) {
+	var threads []ModmailThread
+	err := s.db.Select(&threads, `
+		SELECT ModmailThread, GuildID, UserID, ThreadID, Status, CreatedAt
+		FROM ModmailThread
+		WHERE UserID = ? AND Status = ?
+		ORDER BY ModmailThread`,
+		userID, modmailStatusOpen)
+	return threads, err
+}
+
+func (s moddingDataStore) openModmailThreadByChannel(threadID string) (ModmailThread, error) {
+	var thread ModmailThread
+	err := s.db.Get(&thread, `
+		SELECT ModmailThread, GuildID, UserID, ThreadID, Status, CreatedAt
+		FROM ModmailThread
+		WHERE ThreadID = ? AND Status = ?`,
+		threadID, modmailStatusOpen)
+	return thread, err
+}
+
+func (s moddingDataStore) modmailThread(id int, guildID string) (ModmailThread, error) {
+	var thread ModmailThread
+	err := s.db.Get(&thread, `SELECT * FROM ModmailThread WHERE ModmailThread = ? AND GuildID = ?`, id, guildID)
+	return thread, err
+}
+
+// userModmailThreads lists the guild's threads opened by the user, newest first and without their transcripts
+func (s moddingDataStore) userModmailThreads(guildID, userID string) ([]ModmailThread, error) {
+	var threads []ModmailThread
+	err := s.db.Select(&threads, `
+		SELECT ModmailThread, GuildID, UserID, ThreadID, Status, ClosedByID, CreatedAt, ClosedAt
+		FROM ModmailThread
+		WHERE GuildID = ? AND UserID = ?
+		ORDER BY ModmailThread DESC`,
+		guildID, userID)
+	return threads, err
+}
+
+// closeModmailThread only updates open threads, so a thread can't be closed twice
+func (s moddingDataStore) closeModmailThread(id int, closedByID, transcript string) error {
+	res, err := s.db.Exec(`
+		UPDATE ModmailThread SET Status = ?, ClosedByID = ?, Transcript = ?, ClosedAt = CURRENT_TIMESTAMP
+		WHERE ModmailThread = ? AND Status = ?`,
+		modmailStatusClosed, closedByID, transcript, id, modmailStatusOpen)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return errZeroRowsAffected
+	}
+	return err
+}
+
+type ArchivedAttachment struct {
+	ID           int       `db:"ArchivedAttachment"`
+	MessageID    string    `db:"MessageID"`
+	AttachmentID string    `db:"AttachmentID"`
+	GuildID      string    `db:"GuildID"`
+	ChannelID    string    `db:"ChannelID"`
+	Filename     string    `db:"Filename"`
+	ContentType  string    `db:"ContentType"`
+	Path         string    `db:"Path"`
+	Size         int64     `db:"Size"`
+	CreatedAt    time.Time `db:"CreatedAt"`
+}
+
+func (s moddingDataStore) addArchivedAttachment(a ArchivedAttachment) error {
+	_, err := s.db.Exec(`
+		INSERT INTO ArchivedAttachment (MessageID, AttachmentID, GuildID, ChannelID, Filename, ContentType, Path, Size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.MessageID, a.AttachmentID, a.GuildID, a.ChannelID, a.Filename, a.ContentType, a.Path, a.Size)
+	return err
+}
+
+func (s moddingDataStore) messageArchivedAttachments(messageID string) ([]ArchivedAttachment, error) {
+	var attachments []ArchivedAttachment
+	err := s.db.Select(&attachments, `SELECT * FROM ArchivedAttachment WHERE MessageID = ? ORDER BY ArchivedAttachment`, messageID)
+	return attachments, err
+}
+
+func (s moddingDataStore) removeArchivedAttachment(id int) error {
+	_, err := s.db.Exec(`DELETE FROM ArchivedAttachment WHERE ArchivedAttachment = ?`, id)
+	return err
+}
+
+// archivedAttachmentsSize returns the total size of the guild's archived attachments, or of every guild if guildID is empty
+func (s moddingDataStore) archivedAttachmentsSize(guildID string) (int64, error) {
+	var size int64
+	err := s.db.Get(&size, `SELECT COALESCE(SUM(Size), 0) FROM ArchivedAttachment WHERE ? = '' OR GuildID = ?`, guildID, guildID)
+	return size, err
+}
+
+// oldestArchivedAttachment returns the oldest archived attachment of the guild, or of every guild if guildID is empty
+func (s moddingDataStore) oldestArchivedAttachment(guildID string) (ArchivedAttachment, error) {
+	var a ArchivedAttachment
+	err := s.db.Get(&a, `SELECT * FROM ArchivedAttachment WHERE ? = '' OR GuildID = ? ORDER BY ArchivedAttachment LIMIT 1`, guildID, guildID)
+	return a, err
+}
+
+// archivedAttachmentsBefore returns the guild's attachments archived before the given time
+func (s moddingDataStore) archivedAttachmentsBefore(guildID string, before time.Time) ([]ArchivedAttachment, error) {
+	var attachments []ArchivedAttachment
+	err := s.db.Select(&attachments, `SELECT * FROM ArchivedAttachment WHERE GuildID = ? AND CreatedAt < ?`, guildID, sqliteTimestamp(before))
+	return attachments, err
+}
+
+// archivedAttachmentGuildIDs returns the guilds that have any archived attachments
+func (s moddingDataStore) archivedAttachmentGuildIDs() ([]string, error) {
+	var guildIDs []string
+	err := s.db.Select(&guildIDs, `SELECT DISTINCT GuildID FROM ArchivedAttachment`)
+	return guildIDs, err
+}
+
 // server
 
 type serverDataStore struct {
@@ -672,6 +1909,20 @@ func (s scheduledActionsDataStore) getScheduledActionsByTargetIDAndActionTypeAnd
 	return actions, nil
 }
 
+// getGuildScheduledActionsByActionType only works for actions whose data starts with the guild ID, like REMOVE_ROLE
+func (s scheduledActionsDataStore) getGuildScheduledActionsByActionType(guildID, actionType string) ([]ScheduledAction, error) {
+	var actions []ScheduledAction
+	err := s.db.Select(&actions, `
+		SELECT ScheduledActions, CreatedAt, ScheduledFor, TargetID, TargetType, ActionType, ActionData
+		FROM ScheduledActions
+		WHERE ActionType = ? AND ActionData LIKE ?
+		ORDER BY ScheduledFor ASC`, actionType, guildID+";%")
+	if err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
 func (s scheduledActionsDataStore) removeScheduledAction(id int) error {
 	_, err := s.db.Exec(`DELETE FROM ScheduledActions WHERE ScheduledActions = ?`, id)
 	return err
@@ -720,6 +1971,27 @@ func createIndex(table, column string, db *sqlx.DB) {
 	db.MustExec(statement)
 }
 
+// sqliteTimestamp formats the time like CURRENT_TIMESTAMP does, so they can be compared
+func sqliteTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// addColumn is for tables created before the column existed, SQLite doesn't support ADD COLUMN IF NOT EXISTS
+func addColumn(table, column, definition string, db *sqlx.DB) {
+	var exists int
+	checkQuery := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`
+	if err := db.Get(&exists, checkQuery, table, column); err != nil {
+		log.Fatalf("Failed to check column existence for %s.%s: %v", table, column, err)
+	}
+
+	if exists > 0 {
+		return
+	}
+
+	statement := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)
+	db.MustExec(statement)
+}
+
 func createTrigger(triggerName, table, event, condition, body string, db *sqlx.DB) {
 	// SQLite doesn’t support CREATE TRIGGER IF NOT EXISTS
 	var exists int
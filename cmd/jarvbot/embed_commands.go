@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	buttonReducerMap["embedcmdmodal"] = handleEmbedCommandModal
+}
+
+// Command Answers
+
+// Format: !addembedcommand !key {"title": "...", "description": "...", ...}
+func answerAddEmbedCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	commandBody := commandPrefixRegex.ReplaceAllString(mc.Content, "")
+	key := strings.TrimSpace(commandPrefixRegex.FindString(commandBody))
+	payload := commandPrefixRegex.ReplaceAllString(commandBody, "")
+
+	embedJSON, err := parseAndValidateEmbedJSON(payload)
+	if err == nil {
+		err = validateAndAddEmbedCommand(key, embedJSON, mc.GuildID, mc.Author.ID)
+	}
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not create the command: "+err.Error())
+		return false
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+func answerReplaceEmbedCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	commandBody := commandPrefixRegex.ReplaceAllString(mc.Content, "")
+	key := strings.TrimSpace(commandPrefixRegex.FindString(commandBody))
+	payload := commandPrefixRegex.ReplaceAllString(commandBody, "")
+
+	embedJSON, err := parseAndValidateEmbedJSON(payload)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not replace the command: "+err.Error())
+		return false
+	}
+
+	commandDS.removeSimpleCommand(key, mc.GuildID)
+	err = validateAndAddEmbedCommand(key, embedJSON, mc.GuildID, mc.Author.ID)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not replace the command: "+err.Error())
+		return false
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+// Slash Command answers
+
+// answerEmbedCommand opens a modal to create or edit an embed command
+// Fields and thumbnails can only be set through the JSON commands, but the modal keeps them when editing
+func answerEmbedCommand(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if !isMod(ds, interactionUser(ic).ID, ic.ChannelID) {
+		ephemeralRespond(ds, ic, userMustBeModMessage)
+		return
+	}
+
+	key := ic.ApplicationCommandData().Options[0].StringValue()
+	if !strings.HasPrefix(key, "!") {
+		key = "!" + key
+	}
+	if len(key) > commandKeyMaxLength || strings.ContainsAny(key, " "+buttonCustomIdSeparator) {
+		ephemeralRespond(ds, ic, "That command key is not valid! :<")
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{}
+	existing, err := commandDS.guildSimpleCommand(key, ic.GuildID)
+	if err == nil && existing.Embed != "" {
+		json.Unmarshal([]byte(existing.Embed), embed)
+	}
+
+	var imageURL, footer, color string
+	if embed.Image != nil {
+		imageURL = embed.Image.URL
+	}
+	if embed.Footer != nil {
+		footer = embed.Footer.Text
+	}
+	if embed.Color != 0 {
+		color = fmt.Sprintf("#%06X", embed.Color)
+	}
+
+	err = ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: "embedcmdmodal" + buttonCustomIdSeparator + key,
+			Title:    truncateString("Embed for "+key, 40),
+			Components: []discordgo.MessageComponent{
+				modalTextInput("title", "Title", discordgo.TextInputShort, embed.Title, embedTitleMaxLength),
+				modalTextInput("description", "Description", discordgo.TextInputParagraph, embed.Description, min(embedDescriptionMaxLength, modalTextInputMaxLength)),
+				modalTextInput("color", "Color (hex, like #5865F2)", discordgo.TextInputShort, color, 7),
+				modalTextInput("image", "Image URL", discordgo.TextInputShort, imageURL, 512),
+				modalTextInput("footer", "Footer", discordgo.TextInputParagraph, footer, embedFooterMaxLength),
+			},
+		},
+	})
+	serverNotifyIfErr("answerEmbedCommand::InteractionRespond", err, ic.GuildID, ds)
+}
+
+// Modal handlers
+
+func handleEmbedCommandModal(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if len(data) < 2 {
+		return fmt.Errorf("unexpected embed command modal data: %v", data)
+	}
+	if !isMod(ds, interactionUser(ic).ID, ic.ChannelID) {
+		return ephemeralRespond(ds, ic, userMustBeModMessage)
+	}
+	key := data[1]
+	values := modalTextValues(ic)
+
+	embed := &discordgo.MessageEmbed{}
+	existing, err := commandDS.guildSimpleCommand(key, ic.GuildID)
+	if err != nil && err != sql.ErrNoRows {
+		ephemeralRespond(ds, ic, "Could not read the command: "+err.Error())
+		return err
+	}
+	if existing.Embed != "" {
+		json.Unmarshal([]byte(existing.Embed), embed)
+	}
+
+	embed.Title = values["title"]
+	embed.Description = values["description"]
+	embed.Image = nil
+	if values["image"] != "" {
+		embed.Image = &discordgo.MessageEmbedImage{URL: values["image"]}
+	}
+	embed.Footer = nil
+	if values["footer"] != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: values["footer"]}
+	}
+	embed.Color = 0
+	if values["color"] != "" {
+		embed.Color, err = parseEmbedColor(values["color"])
+		if err != nil {
+			return ephemeralRespond(ds, ic, "Could not save the embed: "+err.Error())
+		}
+	}
+
+	if err = validateEmbed(embed); err != nil {
+		return ephemeralRespond(ds, ic, "Could not save the embed: "+err.Error())
+	}
+	embedJSON, err := json.Marshal(embed)
+	if err != nil {
+		return err
+	}
+
+	if existing.Key != "" {
+		err = commandDS.setSimpleCommandEmbed(key, string(embedJSON), ic.GuildID)
+	} else {
+		err = validateAndAddEmbedCommand(key, string(embedJSON), ic.GuildID, interactionUser(ic).ID)
+	}
+	if err != nil {
+		return ephemeralRespond(ds, ic, "Could not save the embed: "+err.Error())
+	}
+
+	return ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("Saved %s! This is how it looks:", key),
+			Embeds:  []*discordgo.MessageEmbed{embed},
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// Internal functions
+
+// simpleCommandResponder sends the embed of the command if it has one, and the text response otherwise
+func simpleCommandResponder(cmd SimpleCommand) command {
+	if cmd.Embed == "" {
+		return simpleTextResponse(cmd.Response)
+	}
+	return func(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+		embed := &discordgo.MessageEmbed{}
+		if err := json.Unmarshal([]byte(cmd.Embed), embed); err != nil {
+			serverNotifyIfErr("simpleCommandResponder: broken embed for "+cmd.Key, err, mc.GuildID, ds)
+			return false
+		}
+		_, err := ds.ChannelMessageSendComplex(mc.ChannelID, &discordgo.MessageSend{
+			Content: cmd.Response,
+			Embeds:  []*discordgo.MessageEmbed{embed},
+		})
+		return err == nil
+	}
+}
+
+func validateAndAddEmbedCommand(key, embedJSON, guildID, creatorUserID string) error {
+	if key == "" {
+		return errors.New("Command keys can't be empty")
+	}
+	if len(key) > commandKeyMaxLength {
+		return errors.New("That command key is too long! :<")
+	}
+	return commandDS.addEmbedCommand(key, embedJSON, guildID, creatorUserID)
+}
+
+// parseAndValidateEmbedJSON returns the normalized JSON of the embed, without unknown or empty fields
+func parseAndValidateEmbedJSON(payload string) (string, error) {
+	payload = strings.TrimSpace(payload)
+	payload = strings.TrimPrefix(strings.TrimPrefix(payload, "```json"), "```")
+	payload = strings.TrimSuffix(payload, "```")
+
+	embed := &discordgo.MessageEmbed{}
+	if err := json.Unmarshal([]byte(payload), embed); err != nil {
+		return "", errors.New("that is not a valid embed JSON: " + err.Error())
+	}
+	if err := validateEmbed(embed); err != nil {
+		return "", err
+	}
+	normalized, err := json.Marshal(embed)
+	return string(normalized), err
+}
+
+// validateEmbed checks the embed against Discord's limits, so broken embeds are not stored
+func validateEmbed(embed *discordgo.MessageEmbed) error {
+	length := func(s string) int { return utf8.RuneCountInString(s) }
+	total := length(embed.Title) + length(embed.Description)
+
+	if embed.Title == "" && embed.Description == "" && embed.Image == nil && embed.Thumbnail == nil && len(embed.Fields) == 0 {
+		return errors.New("the embed is empty")
+	}
+	if length(embed.Title) > embedTitleMaxLength {
+		return fmt.Errorf("the title can't be longer than %d characters", embedTitleMaxLength)
+	}
+	if length(embed.Description) > embedDescriptionMaxLength {
+		return fmt.Errorf("the description can't be longer than %d characters", embedDescriptionMaxLength)
+	}
+	if embed.Color < 0 || embed.Color > 0xFFFFFF {
+		return errors.New("the color must be between 0 and 0xFFFFFF")
+	}
+	if len(embed.Fields) > embedMaxFields {
+		return fmt.Errorf("the embed can't have more than %d fields", embedMaxFields)
+	}
+	for _, field := range embed.Fields {
+		if field == nil || field.Name == "" || field.Value == "" {
+			return errors.New("embed fields need a name and a value")
+		}
+		if length(field.Name) > embedFieldNameMaxLength {
+			return fmt.Errorf("field names can't be longer than %d characters", embedFieldNameMaxLength)
+		}
+		if length(field.Value) > embedFieldValueMaxLength {
+			return fmt.Errorf("field values can't be longer than %d characters", embedFieldValueMaxLength)
+		}
+		total += length(field.Name) + length(field.Value)
+	}
+	if embed.Footer != nil {
+		if length(embed.Footer.Text) > embedFooterMaxLength {
+			return fmt.Errorf("the footer can't be longer than %d characters", embedFooterMaxLength)
+		}
+		total += length(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		if length(embed.Author.Name) > embedAuthorNameMaxLength {
+			return fmt.Errorf("the author name can't be longer than %d characters", embedAuthorNameMaxLength)
+		}
+		total += length(embed.Author.Name)
+	}
+	if total > embedTotalMaxLength {
+		return fmt.Errorf("the embed can't have more than %d characters in total", embedTotalMaxLength)
+	}
+
+	urls := []string{embed.URL}
+	if embed.Image != nil {
+		urls = append(urls, embed.Image.URL)
+	}
+	if embed.Thumbnail != nil {
+		urls = append(urls, embed.Thumbnail.URL)
+	}
+	if embed.Footer != nil {
+		urls = append(urls, embed.Footer.IconURL)
+	}
+	if embed.Author != nil {
+		urls = append(urls, embed.Author.URL, embed.Author.IconURL)
+	}
+	for _, u := range urls {
+		if u == "" {
+			continue
+		}
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("'%s' is not a valid http(s) URL", u)
+		}
+	}
+
+	return nil
+}
+
+// parseEmbedColor accepts hex colors like #5865F2 or 0x5865F2, and plain decimal numbers
+func parseEmbedColor(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	var color int64
+	var err error
+	switch {
+	case strings.HasPrefix(s, "#"):
+		color, err = strconv.ParseInt(s[1:], 16, 32)
+	case strings.HasPrefix(strings.ToLower(s), "0x"):
+		color, err = strconv.ParseInt(s[2:], 16, 32)
+	default:
+		color, err = strconv.ParseInt(s, 10, 32)
+	}
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("'%s' is not a valid color", s)
+	}
+	return int(color), nil
+}
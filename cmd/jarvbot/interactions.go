@@ -43,11 +43,16 @@ func buttonCustomIdReducer(ds *discordgo.Session, ic *discordgo.InteractionCreat
 
 func onInteractionCreate(ctx context.Context) func(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
 	return func(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
-		if ic.Type != discordgo.InteractionMessageComponent {
+		var customID string
+		switch ic.Type {
+		case discordgo.InteractionMessageComponent:
+			customID = ic.MessageComponentData().CustomID
+		case discordgo.InteractionModalSubmit:
+			customID = ic.ModalSubmitData().CustomID
+		default:
 			return
 		}
 
-		customID := ic.MessageComponentData().CustomID
 		err := buttonCustomIdReducer(ds, ic, customID)
 		if err != nil {
 			log.Println("Interaction failed for customID", customID, err)
@@ -69,6 +74,16 @@ func editInteractionMessage(ds *discordgo.Session, ic *discordgo.InteractionCrea
 	}
 }
 
+func ephemeralRespond(ds *discordgo.Session, ic *discordgo.InteractionCreate, content string) error {
+	return ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
 func ackInteraction(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
 	ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseDeferredMessageUpdate})
 }
@@ -118,3 +133,34 @@ func buildButtonComponents(buttons []*discordgo.Button) *[]discordgo.MessageComp
 
 	return &components
 }
+
+func modalTextInput(customID, label string, style discordgo.TextInputStyle, value string, maxLength int) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:  customID,
+				Label:     label,
+				Style:     style,
+				Value:     value,
+				MaxLength: maxLength,
+			},
+		},
+	}
+}
+
+// modalTextValues maps the custom IDs of the submitted text inputs to their values
+func modalTextValues(ic *discordgo.InteractionCreate) map[string]string {
+	values := make(map[string]string)
+	for _, row := range ic.ModalSubmitData().Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok {
+				values[input.CustomID] = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	return values
+}
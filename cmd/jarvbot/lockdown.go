@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var lockdownDeniedPermissions int64 = discordgo.PermissionSendMessages | discordgo.PermissionSendMessagesInThreads |
+	discordgo.PermissionCreatePublicThreads | discordgo.PermissionCreatePrivateThreads
+
+// recent joins per guild, only kept for the guild's join window
+var recentJoins = map[string][]time.Time{}
+var lastRaidAlerts = map[string]time.Time{}
+var recentJoinsMutex sync.Mutex
+
+type antiRaidInput struct {
+	Joins    *int   `short:"j" long:"joins" description:"Joins within the window that are considered a raid, 0 disables the raid detection"`
+	Window   string `short:"w" long:"window" description:"The sliding window where joins are counted, format: 99m99s, up to 10m"`
+	Auto     string `long:"auto" choice:"on" choice:"off" description:"If on, the server is locked down when a raid is detected"`
+	Duration string `short:"d" long:"duration" description:"Automatic lockdowns are lifted after this duration, format: 99d99h99m. 0s keeps them until !unlock"`
+	Channels string `short:"c" long:"channels" description:"Channels locked by !lockdown and automatic lockdowns, separated by spaces or commas. 'none' removes them"`
+}
+
+// Command Answers
+
+// Format: !antiraid [flags]
+// Without flags, it shows the current settings
+func answerAntiRaid(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var input antiRaidInput
+	if err := parseCommandArgs(&input, mc.Content); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+
+	properties := map[string]string{}
+	if input.Joins != nil {
+		if *input.Joins < 0 {
+			ds.ChannelMessageSend(mc.ChannelID, "The joins can't be negative")
+			return false
+		}
+		properties[serverPropRaidJoinThreshold] = strconv.Itoa(*input.Joins)
+	}
+	if input.Window != "" {
+		window := stringToDuration(input.Window)
+		if window <= 0 || window > raidMaxJoinWindow {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The window must be a duration like 30s, up to %s", humanDurationString(raidMaxJoinWindow)))
+			return false
+		}
+		properties[serverPropRaidJoinWindow] = strconv.Itoa(int(window.Seconds()))
+	}
+	if input.Auto != "" {
+		properties[serverPropRaidAutoLockdown] = map[string]string{"on": serverPropYes, "off": serverPropNo}[input.Auto]
+	}
+	if input.Duration != "" {
+		duration, ok := parseDurationFlag(input.Duration)
+		if !ok {
+			ds.ChannelMessageSend(mc.ChannelID, "The duration must be a duration like 1h, 0s keeps the lockdowns until !unlock")
+			return false
+		}
+		properties[serverPropRaidLockdownDuration] = strconv.Itoa(int(duration.Seconds()))
+	}
+	if input.Channels != "" {
+		channelIDs := extractDiscordIDs(input.Channels)
+		if len(channelIDs) > lockdownMaxChannels {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Too many channels!, the max is %d", lockdownMaxChannels))
+			return false
+		}
+		for _, id := range channelIDs {
+			if !channelBelongsToGuild(ds, id, mc.GuildID) {
+				ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The channel %s doesn't belong to this server", id))
+				return false
+			}
+		}
+		properties[serverPropLockdownChannels] = strings.Join(channelIDs, serverPropListSeparator)
+	}
+
+	for name, value := range properties {
+		if err := serverDS.setServerProperty(mc.GuildID, name, value); err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Could not save the anti-raid settings: "+err.Error())
+			return false
+		}
+	}
+
+	_, err := ds.ChannelMessageSendEmbed(mc.ChannelID, antiRaidSettingsEmbed(mc.GuildID))
+	return err == nil
+}
+
+func antiRaidSettingsEmbed(guildID string) *discordgo.MessageEmbed {
+	detection := "Disabled"
+	if threshold := raidJoinThreshold(guildID); threshold > 0 {
+		detection = fmt.Sprintf("%d joins in %s", threshold, humanDurationString(raidJoinWindow(guildID)))
+	}
+	auto := "No, only alerts"
+	if autoLockdown, _ := serverDS.getServerProperty(guildID, serverPropRaidAutoLockdown); autoLockdown == serverPropYes {
+		auto = "Yes, until !unlock"
+		if duration := raidLockdownDuration(guildID); duration > 0 {
+			auto = "Yes, for " + humanDurationString(duration)
+		}
+	}
+	channels := "None, configure them with -c"
+	if channelIDs, _ := serverDS.GetListProperty(guildID, serverPropLockdownChannels, serverPropListSeparator); len(channelIDs) > 0 {
+		channels = "<#" + strings.Join(channelIDs, "> <#") + ">"
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "Anti-raid settings",
+		Color: colorBlue,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Raid detection", Value: detection, Inline: true},
+			{Name: "Automatic lockdown", Value: auto, Inline: true},
+			{Name: "Lockdown channels", Value: truncateString(channels, embedFieldValueMaxLength-1)},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "Change them with !antiraid [flags], see !antiraid --help"},
+	}
+}
+
+// Format: !lockdown [duration]
+func answerLockdown(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var duration time.Duration
+	if args := strings.TrimSpace(commandPrefixRegex.ReplaceAllString(mc.Content, "")); args != "" {
+		duration = stringToDuration(args)
+		if duration <= 0 {
+			ds.ChannelMessageSend(mc.ChannelID, "Format: !lockdown [duration, like 1h]")
+			return false
+		}
+	}
+
+	locked, err := lockdownGuild(ds, mc.GuildID, duration, mc.Author.ID, "Manual lockdown")
+	if locked == 0 && err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, "No lockdown channels configured! Add them with !antiraid -c #channel1 #channel2")
+		return false
+	}
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Locked %d channels, but something went wrong: %s", locked, err.Error()))
+		return false
+	}
+
+	response := fmt.Sprintf("Locked %d channels until !unlock", locked)
+	if duration > 0 {
+		response = fmt.Sprintf("Locked %d channels for %s", locked, humanDurationString(duration))
+	}
+	ds.ChannelMessageSend(mc.ChannelID, response)
+	return true
+}
+
+func answerUnlock(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	unlocked, err := unlockGuild(ds, mc.GuildID, mc.Author.ID)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Unlocked %d channels, but something went wrong: %s", unlocked, err.Error()))
+		return false
+	}
+	if unlocked == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "The server is not locked down :3")
+		return false
+	}
+	ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Unlocked %d channels, their previous permissions have been restored", unlocked))
+	return true
+}
+
+// Internal functions
+
+func raidJoinThreshold(guildID string) int {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropRaidJoinThreshold)
+	threshold, _ := strconv.Atoi(raw)
+	return threshold
+}
+
+func raidJoinWindow(guildID string) time.Duration {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropRaidJoinWindow)
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return raidDefaultJoinWindow
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func raidLockdownDuration(guildID string) time.Duration {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropRaidLockdownDuration)
+	seconds, _ := strconv.Atoi(raw)
+	return time.Duration(seconds) * time.Second
+}
+
+// raidAlertChannelID prefers the errors channel, since raid alerts are meant for the mods
+func raidAlertChannelID(guildID string) string {
+	if channelID, err := serverDS.getServerProperty(guildID, serverPropErrorsHere); err == nil && channelID != "" {
+		return channelID
+	}
+	channelID, _ := serverDS.getServerProperty(guildID, serverPropAnnounceHere)
+	return channelID
+}
+
+// trackJoin adds a join to the guild's recent joins. Returns how many joins happened in the window,
+// and true if a raid alert should be sent (at most once per raidAlertCooldown)
+func trackJoin(guildID string, threshold int, window time.Duration) (int, bool) {
+	recentJoinsMutex.Lock()
+	defer recentJoinsMutex.Unlock()
+
+	windowStart := time.Now().Add(-window)
+	joins := append(recentJoins[guildID], time.Now())
+	for len(joins) > 0 && (joins[0].Before(windowStart) || len(joins) > threshold) {
+		joins = joins[1:]
+	}
+	recentJoins[guildID] = joins
+
+	if len(joins) < threshold || time.Since(lastRaidAlerts[guildID]) < raidAlertCooldown {
+		return len(joins), false
+	}
+	lastRaidAlerts[guildID] = time.Now()
+	return len(joins), true
+}
+
+// checkRaid is called on every join, it alerts the mods and locks the server down if configured to
+func checkRaid(ds *discordgo.Session, guildID string) {
+	threshold := raidJoinThreshold(guildID)
+	if threshold <= 0 {
+		return
+	}
+	window := raidJoinWindow(guildID)
+	joins, alert := trackJoin(guildID, threshold, window)
+	if !alert {
+		return
+	}
+
+	msg := fmt.Sprintf("🚨 **Possible raid!** %d members joined in less than %s", joins, humanDurationString(window))
+	if autoLockdown, _ := serverDS.getServerProperty(guildID, serverPropRaidAutoLockdown); autoLockdown == serverPropYes {
+		duration := raidLockdownDuration(guildID)
+		locked, err := lockdownGuild(ds, guildID, duration, ds.State.User.ID, "Automatic raid lockdown")
+		serverNotifyIfErr("checkRaid::lockdownGuild", err, guildID, ds)
+		switch {
+		case locked == 0:
+			msg += "\nCould not lock the server down, are the lockdown channels configured? (!antiraid -c)"
+		case duration > 0:
+			msg += fmt.Sprintf("\nLocked %d channels for %s, new members will be quarantined. Use !unlock to end it early", locked, humanDurationString(duration))
+		default:
+			msg += fmt.Sprintf("\nLocked %d channels, new members will be quarantined. Use !unlock to end it", locked)
+		}
+	} else {
+		msg += "\nUse !lockdown to lock the server down"
+	}
+
+	if channelID := raidAlertChannelID(guildID); channelID != "" {
+		_, err := ds.ChannelMessageSend(channelID, msg)
+		serverNotifyIfErr("checkRaid::ChannelMessageSend", err, guildID, ds)
+	}
+}
+
+// quarantineIfLockedDown sends members that join during a lockdown to the shadow realm until the lockdown ends
+// Returns true if the guild is locked down, even if the quarantine failed
+func quarantineIfLockedDown(ds *discordgo.Session, guildID, userID string) bool {
+	locked, err := moddingDS.isGuildLockedDown(guildID)
+	if err != nil || !locked {
+		serverNotifyIfErr("quarantineIfLockedDown::isGuildLockedDown", err, guildID, ds)
+		return false
+	}
+
+	duration := raidQuarantineDuration
+	if unlocks, err := schedulerDS.getScheduledActionsByTargetIDAndActionType(guildID, actionTypeUnlock); err == nil && len(unlocks) > 0 {
+		duration = max(time.Until(unlocks[0].ScheduledFor), time.Minute)
+	}
+
+	timeoutRoleID, err := resolveTimeoutRoleID(ds, guildID)
+	if err == nil {
+		_, err = sendToShadowRealm(ds, guildID, userID, timeoutRoleID, duration, ds.State.User.ID, caseSourceLockdown, "Joined during a lockdown")
+	}
+	if err == nil {
+		// the quarantine lasts until unlockGuild releases them
+		err = moddingDS.addLockdownQuarantine(guildID, userID)
+	}
+	serverNotifyIfErr("quarantineIfLockedDown::sendToShadowRealm", err, guildID, ds)
+	return true
+}
+
+// lockdownGuild denies sending messages to @everyone in the lockdown channels, saving their previous overwrites
+// A positive duration schedules the unlock. Returns how many channels were locked
+func lockdownGuild(ds *discordgo.Session, guildID string, duration time.Duration, actorID, reason string) (int, error) {
+	channelIDs, err := serverDS.GetListProperty(guildID, serverPropLockdownChannels, serverPropListSeparator)
+	if err != nil || len(channelIDs) == 0 {
+		return 0, err
+	}
+
+	locked := 0
+	var errs []error
+	for _, channelID := range channelIDs {
+		if err := lockChannel(ds, guildID, channelID); err != nil {
+			errs = append(errs, fmt.Errorf("<#%s>: %w", channelID, err))
+			continue
+		}
+		locked++
+	}
+
+	// the new lockdown replaces the unlock of a previous one, so an indefinite lockdown is not lifted by it
+	if locked > 0 {
+		removeScheduledUnlocks(guildID)
+	}
+	if locked > 0 && duration > 0 {
+		err = schedulerDS.addScheduledActionAfterDuration(duration, guildID, targetTypeGuild, actionTypeUnlock, "")
+		errs = append(errs, err)
+	}
+
+	if locked > 0 {
+		description := fmt.Sprintf("By: <@%s>\nReason: %s\nChannels: %d", actorID, reason, locked)
+		if duration > 0 {
+			description += fmt.Sprintf("\nUnlocks <t:%d:R>", time.Now().Add(duration).Unix())
+		}
+		sendModLog(ds, guildID, &discordgo.MessageEmbed{
+			Title:       "Server locked down",
+			Color:       colorRed,
+			Description: description,
+		})
+	}
+	return locked, errors.Join(errs...)
+}
+
+func lockChannel(ds *discordgo.Session, guildID, channelID string) error {
+	channel, err := ds.State.Channel(channelID)
+	if err != nil {
+		if channel, err = ds.Channel(channelID); err != nil {
+			return err
+		}
+	}
+	if channel.GuildID != guildID {
+		return errors.New("the channel does not belong to this server")
+	}
+
+	// the @everyone role has the same ID as the guild
+	saved := LockdownOverwrite{GuildID: guildID, ChannelID: channelID}
+	for _, o := range channel.PermissionOverwrites {
+		if o.ID == guildID && o.Type == discordgo.PermissionOverwriteTypeRole {
+			saved.HadOverwrite, saved.Allow, saved.Deny = true, o.Allow, o.Deny
+		}
+	}
+	if err = moddingDS.addLockdownOverwrite(saved); err != nil {
+		return err
+	}
+	return ds.ChannelPermissionSet(channelID, guildID, discordgo.PermissionOverwriteTypeRole,
+		saved.Allow&^lockdownDeniedPermissions, saved.Deny|lockdownDeniedPermissions)
+}
+
+// unlockGuild restores the overwrites saved by lockdownGuild, returns how many channels were unlocked
+func unlockGuild(ds *discordgo.Session, guildID, actorID string) (int, error) {
+	overwrites, err := moddingDS.lockdownOverwrites(guildID)
+	if err != nil {
+		return 0, err
+	}
+	removeScheduledUnlocks(guildID)
+
+	unlocked := 0
+	var errs []error
+	for _, o := range overwrites {
+		if o.HadOverwrite {
+			err = ds.ChannelPermissionSet(o.ChannelID, guildID, discordgo.PermissionOverwriteTypeRole, o.Allow, o.Deny)
+		} else {
+			err = ds.ChannelPermissionDelete(o.ChannelID, guildID)
+		}
+		// deleted channels can't be restored, so they are forgotten too
+		if err != nil && !isUnknownChannelErr(err) {
+			errs = append(errs, fmt.Errorf("<#%s>: %w", o.ChannelID, err))
+			continue
+		}
+		errs = append(errs, moddingDS.removeLockdownOverwrite(o.ID))
+		unlocked++
+	}
+
+	released, err := releaseLockdownQuarantines(ds, guildID)
+	errs = append(errs, err)
+
+	if unlocked > 0 || released > 0 {
+		sendModLog(ds, guildID, &discordgo.MessageEmbed{
+			Title:       "Server unlocked",
+			Color:       colorGreen,
+			Description: fmt.Sprintf("By: <@%s>\nChannels: %d\nReleased members: %d", actorID, unlocked, released),
+		})
+	}
+	return unlocked, errors.Join(errs...)
+}
+
+// releaseLockdownQuarantines releases the members that joined during the lockdown, returns how many were released
+func releaseLockdownQuarantines(ds *discordgo.Session, guildID string) (int, error) {
+	userIDs, err := moddingDS.lockdownQuarantinedUserIDs(guildID)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	var errs []error
+	for _, userID := range userIDs {
+		ok, err := releaseFromShadowRealm(ds, guildID, userID)
+		if err != nil && !isUnknownMemberErr(err) {
+			errs = append(errs, fmt.Errorf("<@%s>: %w", userID, err))
+			continue
+		}
+		if ok {
+			released++
+		}
+		errs = append(errs, moddingDS.removeLockdownQuarantine(guildID, userID))
+	}
+	return released, errors.Join(errs...)
+}
+
+func removeScheduledUnlocks(guildID string) {
+	unlocks, _ := schedulerDS.getScheduledActionsByTargetIDAndActionType(guildID, actionTypeUnlock)
+	for _, a := range unlocks {
+		schedulerDS.removeScheduledAction(a.ID)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var logIgnoreMentionRegex = regexp.MustCompile(`^<(#|@&|@!?)(\d+)>$`)
+
+// Command Answers
+
+// Format: !logignore [add|remove #channel|@role|@user|ID...]
+// Ignoring a category also ignores its channels
+func answerLogIgnore(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) == 0 {
+		return answerLogIgnoreStatus(ds, mc)
+	}
+
+	action := strings.ToLower(args[0])
+	if (action != "add" && action != "remove") || len(args) < 2 {
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !logignore [add|remove #channel|@role|@user|ID...]")
+		return false
+	}
+
+	for _, arg := range args[1:] {
+		propName, id := logIgnoreTarget(ds, mc.GuildID, arg)
+		if propName == "" {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("I don't know what '%s' is, use a channel, role or user mention or ID", arg))
+			return false
+		}
+
+		var err error
+		if action == "add" {
+			current, _ := serverDS.GetListProperty(mc.GuildID, propName, serverPropListSeparator)
+			if len(current) >= logIgnoreMaxPerList {
+				ds.ChannelMessageSend(mc.ChannelID, "Too many ignored things!, please clean up before adding more :3")
+				return false
+			}
+			err = serverDS.AddToListProperty(mc.GuildID, propName, id, serverPropListSeparator)
+		} else {
+			err = serverDS.RemoveFromListProperty(mc.GuildID, propName, id, serverPropListSeparator)
+		}
+		if err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Could not update the ignore list: "+err.Error())
+			return false
+		}
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+func answerLogIgnoreStatus(ds *discordgo.Session, mc *discordgo.MessageCreate) bool {
+	channels, _ := serverDS.GetListProperty(mc.GuildID, serverPropLogIgnoredChannels, serverPropListSeparator)
+	roles, _ := serverDS.GetListProperty(mc.GuildID, serverPropLogIgnoredRoles, serverPropListSeparator)
+	users, _ := serverDS.GetListProperty(mc.GuildID, serverPropLogIgnoredUsers, serverPropListSeparator)
+
+	mentionList := func(ids []string, format string) string {
+		if len(ids) == 0 {
+			return "None"
+		}
+		mentions := make([]string, len(ids))
+		for i, id := range ids {
+			mentions[i] = fmt.Sprintf(format, id)
+		}
+		return truncateString(strings.Join(mentions, " "), embedFieldValueMaxLength)
+	}
+
+	_, err := ds.ChannelMessageSendComplex(mc.ChannelID, &discordgo.MessageSend{
+		Embed: &discordgo.MessageEmbed{
+			Title: "Ignored by the message logs",
+			Color: colorBlue,
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Channels and categories", Value: mentionList(channels, "<#%s>")},
+				{Name: "Roles", Value: mentionList(roles, "<@&%s>")},
+				{Name: "Users", Value: mentionList(users, "<@%s>")},
+				{Name: "Minimum edit change", Value: fmt.Sprintf("%d characters", logMinEditDistance(mc.GuildID))},
+			},
+			Footer: &discordgo.MessageEmbedFooter{Text: "Change them with !logignore add|remove, !logeditthreshold and !logredact"},
+		},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err == nil
+}
+
+// Format: !logeditthreshold <characters>
+func answerLogEditThreshold(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) != 1 {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Edits that change less than %d characters are not logged\nFormat: !logeditthreshold <characters>", logMinEditDistance(mc.GuildID)))
+		return false
+	}
+
+	threshold, err := strconv.Atoi(args[0])
+	if err != nil || threshold < 1 || threshold > discordMaxMessageLength {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The threshold must be a number between 1 and %d", discordMaxMessageLength))
+		return false
+	}
+
+	err = serverDS.setServerProperty(mc.GuildID, serverPropLogMinEditDistance, strconv.Itoa(threshold))
+	serverNotifyIfErr("answerLogEditThreshold", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Okay! Will only log edits that change at least %d characters", threshold))
+	}
+	return err == nil
+}
+
+// Format: !logredact [add <regex>|remove <number>]
+func answerLogRedact(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	content := strings.TrimSpace(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	action, arg, _ := strings.Cut(content, " ")
+	arg = strings.TrimSpace(arg)
+	patterns, err := serverDS.GetListProperty(mc.GuildID, serverPropLogRedactPatterns, serverPropPatternSeparator)
+	if err != nil {
+		serverNotifyIfErr("answerLogRedact::GetListProperty", err, mc.GuildID, ds)
+		return false
+	}
+
+	switch strings.ToLower(action) {
+	case "":
+		if len(patterns) == 0 {
+			ds.ChannelMessageSend(mc.ChannelID, "No redaction patterns, wanna add some? :3 (!logredact add <regex>)")
+			return true
+		}
+		var b strings.Builder
+		for i, pattern := range patterns {
+			b.WriteString(fmt.Sprintf("**%d**: `%s`\n", i+1, pattern))
+		}
+		_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, &discordgo.MessageEmbed{
+			Title:       "Redaction patterns",
+			Color:       colorBlue,
+			Description: b.String(),
+		})
+		return err == nil
+	case "add":
+		if arg == "" || len(arg) > logRedactPatternMaxLength || strings.Contains(arg, serverPropPatternSeparator) {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The pattern must be a single line of up to %d characters", logRedactPatternMaxLength))
+			return false
+		}
+		if _, err := regexp.Compile(arg); err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "That is not a valid regex: "+err.Error())
+			return false
+		}
+		if len(patterns) >= logRedactMaxPatterns {
+			ds.ChannelMessageSend(mc.ChannelID, "Too many redaction patterns!, please clean up before adding more :3")
+			return false
+		}
+		err = serverDS.AddToListProperty(mc.GuildID, serverPropLogRedactPatterns, arg, serverPropPatternSeparator)
+	case "remove":
+		n, convErr := strconv.Atoi(arg)
+		if convErr != nil || n < 1 || n > len(patterns) {
+			ds.ChannelMessageSend(mc.ChannelID, "Use the pattern number shown in !logredact")
+			return false
+		}
+		err = serverDS.SetListProperty(mc.GuildID, serverPropLogRedactPatterns, slices.Delete(patterns, n-1, n), serverPropPatternSeparator)
+	default:
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !logredact [add <regex>|remove <number>]")
+		return false
+	}
+
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not update the redaction patterns: "+err.Error())
+		return false
+	}
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+// Internal functions
+
+// logIgnoreTarget returns the ignore list property and the ID for a mention or raw ID
+// Raw IDs are looked up as channels and roles first, anything else is considered a user
+func logIgnoreTarget(ds *discordgo.Session, guildID, arg string) (string, string) {
+	if match := logIgnoreMentionRegex.FindStringSubmatch(arg); match != nil {
+		switch match[1] {
+		case "#":
+			return serverPropLogIgnoredChannels, match[2]
+		case "@&":
+			return serverPropLogIgnoredRoles, match[2]
+		default:
+			return serverPropLogIgnoredUsers, match[2]
+		}
+	}
+
+	if discordIDRegex.FindString(arg) != arg {
+		return "", ""
+	}
+	if channel, err := ds.State.Channel(arg); err == nil && channel.GuildID == guildID {
+		return serverPropLogIgnoredChannels, arg
+	}
+	if _, err := ds.State.Role(guildID, arg); err == nil {
+		return serverPropLogIgnoredRoles, arg
+	}
+	return serverPropLogIgnoredUsers, arg
+}
+
+// isMessageLogIgnored checks the message channel, its parents, its author and the author's roles against the ignore lists
+func isMessageLogIgnored(ds *discordgo.Session, m *discordgo.Message) bool {
+	if m.GuildID == "" {
+		return false
+	}
+
+	ignoredChannels, _ := serverDS.GetListProperty(m.GuildID, serverPropLogIgnoredChannels, serverPropListSeparator)
+	for _, channelID := range channelAndParentIDs(ds, m.ChannelID) {
+		if slices.Contains(ignoredChannels, channelID) {
+			return true
+		}
+	}
+
+	if m.Author == nil {
+		return false
+	}
+	if ignored, _ := serverDS.ListPropertyContains(m.GuildID, serverPropLogIgnoredUsers, m.Author.ID, serverPropListSeparator); ignored {
+		return true
+	}
+
+	ignoredRoles, _ := serverDS.GetListProperty(m.GuildID, serverPropLogIgnoredRoles, serverPropListSeparator)
+	if len(ignoredRoles) == 0 {
+		return false
+	}
+	member := m.Member
+	if member == nil || len(member.Roles) == 0 {
+		var err error
+		member, err = ds.State.Member(m.GuildID, m.Author.ID)
+		if err != nil {
+			member, err = ds.GuildMember(m.GuildID, m.Author.ID)
+			if err != nil {
+				return false
+			}
+		}
+	}
+	for _, roleID := range member.Roles {
+		if slices.Contains(ignoredRoles, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+func logMinEditDistance(guildID string) int {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropLogMinEditDistance)
+	threshold, err := strconv.Atoi(raw)
+	if err != nil || threshold < 1 {
+		return 1
+	}
+	return threshold
+}
+
+// isSignificantEdit ignores whitespace-only edits and edits smaller than the guild's threshold
+// Embed unfurls do not change the content, so they are skipped too
+func isSignificantEdit(guildID, before, after string) bool {
+	before = strings.Join(strings.Fields(before), " ")
+	after = strings.Join(strings.Fields(after), " ")
+	if before == after {
+		return false
+	}
+	return levenshteinDistance(before, after) >= logMinEditDistance(guildID)
+}
+
+// redactLogContent replaces the text matching the guild's redaction patterns
+func redactLogContent(guildID, content string) string {
+	if content == "" {
+		return content
+	}
+	patterns, _ := serverDS.GetListProperty(guildID, serverPropLogRedactPatterns, serverPropPatternSeparator)
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		content = regex.ReplaceAllString(content, logRedactedText)
+	}
+	return content
+}
+
+// redactedMessageCopy returns a shallow copy of the message with its content redacted, so the state is not modified
+func redactedMessageCopy(m *discordgo.Message) *discordgo.Message {
+	redacted := *m
+	redacted.Content = redactLogContent(m.GuildID, m.Content)
+	return &redacted
+}
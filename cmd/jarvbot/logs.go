@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func onMessageDeleteBulk(ctx context.Context) func(ds *discordgo.Session, md *discordgo.MessageDeleteBulk) {
+	return func(ds *discordgo.Session, md *discordgo.MessageDeleteBulk) {
+		defer func() {
+			if r := recover(); r != nil {
+				adminNotifyIfErr("onMessageDeleteBulk", fmt.Errorf("panic in onMessageDeleteBulk: %s\n%s", r, string(debug.Stack())), ds)
+			}
+		}()
+
+		for _, messageID := range md.Messages {
+			defer discardArchivedAttachments(ds, md.GuildID, messageID)
+		}
+
+		logsChannelID := logChannelID(md.GuildID, logCategoryMessages)
+		if logsChannelID == "" || isMessageLogIgnored(ds, &discordgo.Message{GuildID: md.GuildID, ChannelID: md.ChannelID}) {
+			return
+		}
+
+		// the state removes the bulk deleted messages before this handler runs, so only the message cache can have them
+		var transcript strings.Builder
+		var loggedIDs []string
+		for _, messageID := range md.Messages {
+			m := messageFromCache(messageID)
+			if m == nil {
+				transcript.WriteString(fmt.Sprintf("[unknown message %s]\n", messageID))
+				loggedIDs = append(loggedIDs, messageID)
+				continue
+			}
+			if isMessageLogIgnored(ds, m) {
+				continue
+			}
+			transcript.WriteString(transcriptLine(redactedMessageCopy(m)))
+			loggedIDs = append(loggedIDs, messageID)
+			moddingDS.removeCachedMessage(messageID)
+		}
+
+		_, err := fileMessageSend(ds, logsChannelID,
+			fmt.Sprintf("**%d messages were bulk deleted in <#%s>**", len(md.Messages), md.ChannelID),
+			fmt.Sprintf("bulk_delete_%s.txt", time.Now().UTC().Format("2006-01-02_15-04-05")),
+			transcript.String())
+		serverNotifyIfErr("onMessageDeleteBulk::fileMessageSend", err, md.GuildID, ds)
+
+		// the deferred discards run after this, like in onMessageDeleted
+		for _, messageID := range loggedIDs {
+			sendArchivedAttachments(ds, md.GuildID, logsChannelID, messageID)
+		}
+	}
+}
+
+func onGuildMemberRemove(ctx context.Context) func(ds *discordgo.Session, mr *discordgo.GuildMemberRemove) {
+	return func(ds *discordgo.Session, mr *discordgo.GuildMemberRemove) {
+		defer func() {
+			if r := recover(); r != nil {
+				adminNotifyIfErr("onGuildMemberRemove", fmt.Errorf("panic in onGuildMemberRemove: %s\n%s", r, string(debug.Stack())), ds)
+			}
+		}()
+
+		if mr.Member == nil || mr.User == nil {
+			return
+		}
+		invalidateStaffCache(mr.GuildID, mr.User.ID)
+		description := fmt.Sprintf("%s (%s)\n%s", mr.User.Mention(), mr.User.Username, accountAgeString(mr.User.ID))
+		if !mr.JoinedAt.IsZero() {
+			description += fmt.Sprintf("\nJoined <t:%d:R>", mr.JoinedAt.Unix())
+		}
+		sendLog(ds, mr.GuildID, logCategoryMembers, &discordgo.MessageEmbed{
+			Author:      userEmbedAuthor(mr.User),
+			Color:       colorRed,
+			Title:       "Member left",
+			Description: description,
+		})
+		sendGoodbyeMessage(ds, mr.GuildID, mr.Member)
+	}
+}
+
+func onGuildMemberUpdate(ctx context.Context) func(ds *discordgo.Session, mu *discordgo.GuildMemberUpdate) {
+	return func(ds *discordgo.Session, mu *discordgo.GuildMemberUpdate) {
+		defer func() {
+			if r := recover(); r != nil {
+				adminNotifyIfErr("onGuildMemberUpdate", fmt.Errorf("panic in onGuildMemberUpdate: %s\n%s", r, string(debug.Stack())), ds)
+			}
+		}()
+
+		if mu.Member == nil || mu.User == nil {
+			return
+		}
+		invalidateStaffCache(mu.GuildID, mu.User.ID)
+
+		// without the previous state there is nothing to compare
+		if mu.BeforeUpdate == nil {
+			return
+		}
+		logRoleChanges(ds, mu.BeforeUpdate, mu.Member)
+		logProfileChanges(ds, mu.BeforeUpdate, mu.Member)
+	}
+}
+
+func onChannelCreate(ctx context.Context) func(ds *discordgo.Session, cc *discordgo.ChannelCreate) {
+	return func(ds *discordgo.Session, cc *discordgo.ChannelCreate) {
+		defer func() {
+			if r := recover(); r != nil {
+				adminNotifyIfErr("onChannelCreate", fmt.Errorf("panic in onChannelCreate: %s\n%s", r, string(debug.Stack())), ds)
+			}
+		}()
+
+		if cc.Channel == nil || cc.GuildID == "" {
+			return
+		}
+		sendLog(ds, cc.GuildID, logCategoryChannels, &discordgo.MessageEmbed{
+			Color:       colorGreen,
+			Title:       "Channel created",
+			Description: fmt.Sprintf("<#%s> (%s)", cc.ID, cc.Name),
+		})
+	}
+}
+
+func onChannelDelete(ctx context.Context) func(ds *discordgo.Session, cd *discordgo.ChannelDelete) {
+	return func(ds *discordgo.Session, cd *discordgo.ChannelDelete) {
+		defer func() {
+			if r := recover(); r != nil {
+				adminNotifyIfErr("onChannelDelete", fmt.Errorf("panic in onChannelDelete: %s\n%s", r, string(debug.Stack())), ds)
+			}
+		}()
+
+		if cd.Channel == nil || cd.GuildID == "" {
+			return
+		}
+		sendLog(ds, cd.GuildID, logCategoryChannels, &discordgo.MessageEmbed{
+			Color:       colorRed,
+			Title:       "Channel deleted",
+			Description: fmt.Sprintf("#%s (%s)", cd.Name, cd.ID),
+		})
+	}
+}
+
+// Command Answers
+
+// Format: !logs [category here|off|default]
+func answerLogs(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) == 0 {
+		return answerLogsStatus(ds, mc)
+	}
+
+	category := strings.ToLower(args[0])
+	if !slices.Contains(logCategories, category) || len(args) != 2 {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Format: !logs <category> here|off|default\nCategories: %s", strings.Join(logCategories, ", ")))
+		return false
+	}
+
+	var value, response string
+	switch strings.ToLower(args[1]) {
+	case "here":
+		value = mc.ChannelID
+		response = fmt.Sprintf("Okay! Will send %s logs in this channel", category)
+	case "off":
+		value = serverPropNo
+		response = fmt.Sprintf("Okay! Will not send %s logs", category)
+	case "default":
+		value = ""
+		response = fmt.Sprintf("Okay! %s logs will use the default settings", category)
+	default:
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !logs <category> here|off|default")
+		return false
+	}
+
+	err := serverDS.setServerProperty(mc.GuildID, serverPropLogCategoryPrefix+category, value)
+	serverNotifyIfErr("answerLogs", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, response)
+	}
+	return err == nil
+}
+
+func answerLogsStatus(ds *discordgo.Session, mc *discordgo.MessageCreate) bool {
+	var b strings.Builder
+	for _, category := range logCategories {
+		channelID := logChannelID(mc.GuildID, category)
+		if channelID == "" {
+			b.WriteString(fmt.Sprintf("**%s**: off\n", category))
+		} else {
+			b.WriteString(fmt.Sprintf("**%s**: <#%s>\n", category, channelID))
+		}
+	}
+	_, err := ds.ChannelMessageSendEmbed(mc.ChannelID, &discordgo.MessageEmbed{
+		Title:       "Log channels",
+		Color:       colorBlue,
+		Description: b.String(),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Change them with !logs <category> here|off|default"},
+	})
+	return err == nil
+}
+
+// Internal functions
+
+// logChannelID returns the channel for the log category, or an empty string if the category is disabled
+func logChannelID(guildID, category string) string {
+	value, _ := serverDS.getServerProperty(guildID, serverPropLogCategoryPrefix+category)
+	if value == serverPropNo || (value == "" && !slices.Contains(logCategoriesEnabledByDefault, category)) {
+		return ""
+	}
+	if value == "" {
+		messageLogsID, _ := serverDS.getServerProperty(guildID, serverPropMessageLogs)
+		return messageLogsID
+	}
+	return value
+}
+
+func sendLog(ds *discordgo.Session, guildID, category string, embed *discordgo.MessageEmbed) {
+	channelID := logChannelID(guildID, category)
+	if channelID == "" {
+		return
+	}
+	_, err := ds.ChannelMessageSendEmbed(channelID, embed)
+	serverNotifyIfErr("sendLog "+category, err, guildID, ds)
+}
+
+func logMemberJoin(ds *discordgo.Session, guildID string, user *discordgo.User) {
+	sendLog(ds, guildID, logCategoryMembers, &discordgo.MessageEmbed{
+		Author:      userEmbedAuthor(user),
+		Color:       colorGreen,
+		Title:       "Member joined",
+		Description: fmt.Sprintf("%s (%s)\n%s", user.Mention(), user.Username, accountAgeString(user.ID)),
+	})
+}
+
+func logRoleChanges(ds *discordgo.Session, before, after *discordgo.Member) {
+	var changes []string
+	for _, roleID := range after.Roles {
+		if !slices.Contains(before.Roles, roleID) {
+			changes = append(changes, fmt.Sprintf("➕ <@&%s>", roleID))
+		}
+	}
+	for _, roleID := range before.Roles {
+		if !slices.Contains(after.Roles, roleID) {
+			changes = append(changes, fmt.Sprintf("➖ <@&%s>", roleID))
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	sendLog(ds, after.GuildID, logCategoryRoles, &discordgo.MessageEmbed{
+		Author:      userEmbedAuthor(after.User),
+		Color:       colorBlue,
+		Title:       "Roles updated",
+		Description: fmt.Sprintf("%s\n%s", after.User.Mention(), strings.Join(changes, "\n")),
+	})
+}
+
+func logProfileChanges(ds *discordgo.Session, before, after *discordgo.Member) {
+	var changes []string
+	if before.Nick != after.Nick {
+		changes = append(changes, fmt.Sprintf("Nickname: `%s` → `%s`", before.Nick, after.Nick))
+	}
+	if before.Avatar != after.Avatar {
+		changes = append(changes, "Server avatar changed")
+	}
+	if before.User != nil && before.User.Avatar != after.User.Avatar {
+		changes = append(changes, "Avatar changed")
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Author:      userEmbedAuthor(after.User),
+		Color:       colorYellow,
+		Title:       "Profile updated",
+		Description: fmt.Sprintf("%s\n%s", after.User.Mention(), strings.Join(changes, "\n")),
+	}
+	if before.Avatar != after.Avatar || (before.User != nil && before.User.Avatar != after.User.Avatar) {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: after.AvatarURL(avatarTargetSize)}
+	}
+	sendLog(ds, after.GuildID, logCategoryProfiles, embed)
+}
+
+func userEmbedAuthor(user *discordgo.User) *discordgo.MessageEmbedAuthor {
+	return &discordgo.MessageEmbedAuthor{
+		Name:    user.Username,
+		IconURL: user.AvatarURL(""),
+	}
+}
+
+func accountAgeString(userID string) string {
+	createdAt, err := discordgo.SnowflakeTimestamp(userID)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("Account created <t:%d:R>", createdAt.Unix())
+}
+
+func transcriptLine(m *discordgo.Message) string {
+	author := "unknown"
+	if m.Author != nil {
+		author = fmt.Sprintf("%s (%s)", m.Author.Username, m.Author.ID)
+	}
+	line := fmt.Sprintf("[%s] %s: %s", m.Timestamp.UTC().Format(time.DateTime), author, m.Content)
+	for _, a := range m.Attachments {
+		line += " " + a.URL
+	}
+	return line + "\n"
+}
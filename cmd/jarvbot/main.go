@@ -97,6 +97,14 @@ func initDiscordSession() *discordgo.Session {
 	ds.AddHandler(onMessageReacted(backgroundCtx))
 	ds.AddHandler(onMessageUnreacted(backgroundCtx))
 	ds.AddHandler(onInteractionCreate(backgroundCtx))
+	ds.AddHandler(onGuildMemberAdd(backgroundCtx))
+	ds.AddHandler(onGuildMemberRemove(backgroundCtx))
+	ds.AddHandler(onGuildMemberUpdate(backgroundCtx))
+	ds.AddHandler(onGuildRoleUpdate(backgroundCtx))
+	ds.AddHandler(onGuildRoleDelete(backgroundCtx))
+	ds.AddHandler(onMessageDeleteBulk(backgroundCtx))
+	ds.AddHandler(onChannelCreate(backgroundCtx))
+	ds.AddHandler(onChannelDelete(backgroundCtx))
 
 	ds.Identify.Intents |= discordgo.IntentGuilds
 	ds.Identify.Intents |= discordgo.IntentGuildMembers
@@ -132,9 +140,14 @@ func initCRONs(ds *discordgo.Session) {
 	initCron("dbBackupCRON", backupCRON, backupCRONFunc(ds))
 	initCron("dailyCheckInCRON", dailyCheckInReminderCRON, dailyCheckInCRONFunc(ds))
 	initCron("cleanStateMessagesCRON", cleanStateMessagesCRON, cleanStateMessagesCRONFunc(ds))
+	initCron("purgeMessageCacheCRON", purgeMessageCacheCRON, purgeMessageCacheCRONFunc(ds))
+	initCron("purgeAttachmentArchiveCRON", purgeAttachmentArchiveCRON, purgeAttachmentArchiveCRONFunc(ds))
+	initCron("pruneSpamTrackerCRON", pruneSpamTrackerCRON, pruneSpamTrackerCRONFunc(ds))
+	initCron("pruneStaffCacheCRON", pruneStaffCacheCRON, pruneStaffCacheCRONFunc(ds))
 	initCron("parametricCRON", parametricReminderCRON, parametricCRONFunc(ds))
 	initCron("playStoreCRON", playStoreReminderCRON, playStoreCRONFunc(ds))
 	initCron("react4RolesCRON", react4RolesCRON, react4RolesCRONFunc(ds))
+	initCron("roleMenusCRON", roleMenusCRON, roleMenusCRONFunc(ds))
 }
 
 // initActionScheduler executes processScheduledActions periodically
@@ -176,7 +189,40 @@ func executeScheduledAction(ds *discordgo.Session, action ScheduledAction) error
 		guildID := split[0]
 		roleID := split[1]
 		err = ds.GuildMemberRoleRemove(guildID, action.TargetID, roleID)
+		if isUnknownMemberErr(err) {
+			// the user left, the role will be restored if they come back before the action is due
+			err = nil
+			break
+		}
 		serverNotifyIfErr(fmt.Sprintf("Couldn't remove role from user <@%s>", action.TargetID), err, guildID, ds)
+		if err == nil {
+			recordModCase(ds, ModCase{GuildID: guildID, ActorID: ds.State.User.ID, TargetID: action.TargetID, Action: caseActionShadowRealmEnd, Source: caseSourceScheduler})
+		}
+	case actionTypeAddRole:
+		split := strings.Split(action.ActionData, ";")
+		if len(split) != 2 {
+			return fmt.Errorf("unexpected data for %s action: %s", actionTypeAddRole, action.ActionData)
+		}
+		guildID := split[0]
+		roleID := split[1]
+		err = ds.GuildMemberRoleAdd(guildID, action.TargetID, roleID)
+		if isUnknownMemberErr(err) {
+			// the user left before getting the role
+			err = nil
+			break
+		}
+		serverNotifyIfErr(fmt.Sprintf("Couldn't add role to user <@%s>", action.TargetID), err, guildID, ds)
+	case actionTypeUnban:
+		guildID := action.ActionData
+		err = ds.GuildBanDelete(guildID, action.TargetID)
+		serverNotifyIfErr(fmt.Sprintf("Couldn't unban user <@%s>", action.TargetID), err, guildID, ds)
+		if err == nil {
+			recordModCase(ds, ModCase{GuildID: guildID, ActorID: ds.State.User.ID, TargetID: action.TargetID, Action: caseActionUnban, Source: caseSourceScheduler})
+		}
+	case actionTypeUnlock:
+		guildID := action.TargetID
+		_, err = unlockGuild(ds, guildID, ds.State.User.ID)
+		serverNotifyIfErr("Couldn't lift the lockdown", err, guildID, ds)
 	case actionTypeFixedMessageAuthor:
 		schedulerDS.removeScheduledAction(action.ID)
 	}
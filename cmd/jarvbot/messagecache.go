@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type cachedAttachment struct {
+	Filename    string `json:"filename"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+// whether each guild has the message cache enabled, so every message doesn't need a DB read
+var messageCacheEnabledCache = map[string]bool{}
+var messageCacheEnabledCacheMutex sync.Mutex
+
+// Command Answers
+
+// Format: !messagecache on [retention] | off
+func answerMessageCache(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(args) == 0 {
+		status := "disabled"
+		if isMessageCacheEnabled(mc.GuildID) {
+			status = "enabled, keeping messages for " + humanDurationString(messageCacheRetention(mc.GuildID))
+		}
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The message cache is %s\nFormat: !messagecache on [retention, like 7d] | off", status))
+		return true
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		retention := stateMessageMaxLifetime
+		if len(args) > 1 {
+			retention = stringToDuration(args[1])
+			if retention <= 0 || retention > messageCacheMaxRetention {
+				ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The retention must be a duration like 7d, up to %s", humanDurationString(messageCacheMaxRetention)))
+				return false
+			}
+		}
+		err := serverDS.setServerProperty(mc.GuildID, serverPropMessageCacheRetention, strconv.Itoa(int(retention.Seconds())))
+		if err == nil {
+			err = serverDS.setServerProperty(mc.GuildID, serverPropMessageCache, serverPropYes)
+		}
+		invalidateMessageCacheEnabled(mc.GuildID)
+		serverNotifyIfErr("answerMessageCache::on", err, mc.GuildID, ds)
+		if err == nil {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Okay! Will remember messages for %s so deleted and edited messages can be logged", humanDurationString(retention)))
+		}
+		return err == nil
+	case "off":
+		err := serverDS.setServerProperty(mc.GuildID, serverPropMessageCache, serverPropNo)
+		invalidateMessageCacheEnabled(mc.GuildID)
+		if err == nil {
+			err = moddingDS.purgeGuildMessageCache(mc.GuildID)
+		}
+		serverNotifyIfErr("answerMessageCache::off", err, mc.GuildID, ds)
+		if err == nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Okay! Will not remember messages anymore, the cached ones have been deleted")
+		}
+		return err == nil
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, "Format: !messagecache on [retention, like 7d] | off")
+	return false
+}
+
+// CRONs
+
+func purgeMessageCacheCRONFunc(ds *discordgo.Session) func() {
+	return func() {
+		guildIDs, err := moddingDS.cachedMessageGuildIDs()
+		if err != nil {
+			adminNotifyIfErr("purgeMessageCacheCRONFunc", err, ds)
+			return
+		}
+		for _, guildID := range guildIDs {
+			if !isMessageCacheEnabled(guildID) {
+				err = moddingDS.purgeGuildMessageCache(guildID)
+				adminNotifyIfErr("purgeMessageCacheCRONFunc::purgeGuildMessageCache", err, ds)
+				continue
+			}
+			purged, err := moddingDS.purgeMessageCache(guildID, time.Now().Add(-messageCacheRetention(guildID)))
+			adminNotifyIfErr("purgeMessageCacheCRONFunc::purgeMessageCache", err, ds)
+			if purged > 0 {
+				log.Printf("Purged %d cached messages from guild %s", purged, guildID)
+			}
+		}
+	}
+}
+
+// Internal functions
+
+func isMessageCacheEnabled(guildID string) bool {
+	messageCacheEnabledCacheMutex.Lock()
+	defer messageCacheEnabledCacheMutex.Unlock()
+
+	if enabled, ok := messageCacheEnabledCache[guildID]; ok {
+		return enabled
+	}
+	enabled, err := serverDS.getServerProperty(guildID, serverPropMessageCache)
+	if err != nil && err != sql.ErrNoRows {
+		return false
+	}
+	messageCacheEnabledCache[guildID] = enabled == serverPropYes
+	return enabled == serverPropYes
+}
+
+func invalidateMessageCacheEnabled(guildID string) {
+	messageCacheEnabledCacheMutex.Lock()
+	defer messageCacheEnabledCacheMutex.Unlock()
+	delete(messageCacheEnabledCache, guildID)
+}
+
+func messageCacheRetention(guildID string) time.Duration {
+	raw, err := serverDS.getServerProperty(guildID, serverPropMessageCacheRetention)
+	if err != nil {
+		return stateMessageMaxLifetime
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return stateMessageMaxLifetime
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// shouldCacheMessage is checked before starting cacheGuildMessage, so guilds without the cache don't start a goroutine
+func shouldCacheMessage(m *discordgo.Message) bool {
+	return m != nil && m.GuildID != "" && m.Author != nil && isMessageCacheEnabled(m.GuildID)
+}
+
+// cacheGuildMessage stores the message, or updates the cached one if it was edited
+func cacheGuildMessage(ds *discordgo.Session, m *discordgo.Message) {
+	attachments := make([]cachedAttachment, len(m.Attachments))
+	for i, a := range m.Attachments {
+		attachments[i] = cachedAttachment{Filename: a.Filename, URL: a.URL, ContentType: a.ContentType, Size: a.Size}
+	}
+	attachmentsJSON, err := json.Marshal(attachments)
+	if err != nil {
+		serverNotifyIfErr("cacheGuildMessage::Marshal", err, m.GuildID, ds)
+		return
+	}
+
+	sentAt := m.Timestamp
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	err = moddingDS.cacheMessage(CachedMessage{
+		MessageID:    m.ID,
+		GuildID:      m.GuildID,
+		ChannelID:    m.ChannelID,
+		AuthorID:     m.Author.ID,
+		AuthorName:   m.Author.Username,
+		AuthorAvatar: m.Author.Avatar,
+		Content:      m.Content,
+		Attachments:  string(attachmentsJSON),
+		SentAt:       sentAt,
+	})
+	serverNotifyIfErr("cacheGuildMessage", err, m.GuildID, ds)
+}
+
+// messageFromCache rebuilds the message from the cache, returns nil if it was not cached
+func messageFromCache(messageID string) *discordgo.Message {
+	cached, err := moddingDS.cachedMessage(messageID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("messageFromCache:", err)
+		}
+		return nil
+	}
+
+	var attachments []cachedAttachment
+	json.Unmarshal([]byte(cached.Attachments), &attachments)
+	m := &discordgo.Message{
+		ID:        cached.MessageID,
+		GuildID:   cached.GuildID,
+		ChannelID: cached.ChannelID,
+		Content:   cached.Content,
+		Timestamp: cached.SentAt,
+		Author: &discordgo.User{
+			ID:       cached.AuthorID,
+			Username: cached.AuthorName,
+			Avatar:   cached.AuthorAvatar,
+		},
+	}
+	for _, a := range attachments {
+		m.Attachments = append(m.Attachments, &discordgo.MessageAttachment{
+			Filename:    a.Filename,
+			URL:         a.URL,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+		})
+	}
+	return m
+}
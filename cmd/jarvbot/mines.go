@@ -255,7 +255,7 @@ func newMessageMineCheck(ds *discordgo.Session, mc *discordgo.MessageCreate) {
 }
 
 func processMinesetTrigger(ds *discordgo.Session, mc *discordgo.MessageCreate, mineset *MineSet) {
-	timeoutRole, err := getTimeoutRole(ds, mc.GuildID)
+	timeoutRoleID, err := resolveTimeoutRoleID(ds, mc.GuildID)
 	if err != nil {
 		serverNotifyIfErr("fetch timeout role", err, mc.GuildID, ds)
 		return
@@ -264,7 +264,7 @@ func processMinesetTrigger(ds *discordgo.Session, mc *discordgo.MessageCreate, m
 	// Mine nuke logic
 	nukeLuck := rand.Float64()
 	if nukeLuck <= minesNukeChance {
-		handleNuke(ds, mc.ChannelID, mc.GuildID, timeoutRole.ID, minesNukeResponse)
+		handleNuke(ds, mc.ChannelID, mc.GuildID, timeoutRoleID, minesNukeResponse, mc.Author.ID)
 		err = serverDS.decrementMines(mineset.ID, mineset.Amount, 4)
 		adminNotifyIfErr("decrementMines", err, ds)
 		return
@@ -277,10 +277,8 @@ func processMinesetTrigger(ds *discordgo.Session, mc *discordgo.MessageCreate, m
 	if mineset.DurationSeconds == 0 {
 		return
 	}
-	if err := ds.GuildMemberRoleAdd(mc.GuildID, mc.Author.ID, timeoutRole.ID); err == nil {
-		duration := time.Duration(mineset.DurationSeconds) * time.Second
-		removeShadowRealmRoleAfterDuration(mc.GuildID, mc.Author.ID, timeoutRole.ID, duration)
-	}
+	duration := time.Duration(mineset.DurationSeconds) * time.Second
+	sendToShadowRealm(ds, mc.GuildID, mc.Author.ID, timeoutRoleID, duration, ds.State.User.ID, caseSourceMine, fmt.Sprintf("Mineset #%d", mineset.ID))
 }
 
 func buildMineMessage(ds *discordgo.Session, mc *discordgo.MessageCreate, mineset *MineSet) string {
@@ -291,9 +289,7 @@ func buildMineMessage(ds *discordgo.Session, mc *discordgo.MessageCreate, minese
 		message = rngx.Pick(mineTriggerMessages)
 	}
 	// cheap replacements
-	message = strings.Replace(message, "<user>", mc.Author.Mention(), 1)
-	message = strings.Replace(message, "<joinyear>", mc.Member.JoinedAt.Format("2006"), 1)
-	message = strings.Replace(message, "<curryear>", time.Now().Format("2006"), 1)
+	message = replaceMemberPlaceholders(message, mc.Author, mc.Member.JoinedAt)
 	// more expensive replacements
 	if strings.Contains(message, "<role>") {
 		rolename := getTimeoutRoleName(ds, mc.GuildID)
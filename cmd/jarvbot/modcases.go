@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Slash Command answers
+
+func answerCase(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	caseNumber := optionIntValueOrZero(options["number"])
+
+	c, err := moddingDS.modCase(ic.GuildID, caseNumber)
+	if err == sql.ErrNoRows {
+		textRespond(ds, ic, fmt.Sprintf("Could not find case #%d u_u", caseNumber))
+		return
+	}
+	if err != nil {
+		textRespond(ds, ic, "Couldn't get the case: "+err.Error())
+		return
+	}
+
+	err = ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:          []*discordgo.MessageEmbed{modCaseEmbed(c)},
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	serverNotifyIfErr("answerCase", err, ic.GuildID, ds)
+}
+
+func answerCases(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	user := options["user"].UserValue(ds)
+	page := max(optionIntValueOrZero(options["page"]), 1)
+
+	cases, total, err := moddingDS.userModCases(ic.GuildID, user.ID, page-1, modCasesPageSize)
+	if err != nil {
+		textRespond(ds, ic, "Couldn't get the cases: "+err.Error())
+		return
+	}
+
+	pageCount := max((total+modCasesPageSize-1)/modCasesPageSize, 1)
+	responseMsg := fmt.Sprintf("%s has %d cases (page %d/%d):\n", user.Mention(), total, page, pageCount)
+	for _, c := range cases {
+		responseMsg += c.ShortString() + "\n"
+	}
+
+	if len(responseMsg) < discordMaxMessageLength {
+		textRespond(ds, ic, responseMsg)
+	} else {
+		interactionFileRespond(ds, ic, "That's a lot of cases", fmt.Sprintf("%s_cases.txt", user.Username), responseMsg)
+	}
+}
+
+func answerCaseReason(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	caseNumber := optionIntValueOrZero(options["number"])
+	reason := options["reason"].StringValue()
+
+	err := moddingDS.setModCaseReason(ic.GuildID, caseNumber, reason, interactionUser(ic).ID)
+	if err == errZeroRowsAffected {
+		textRespond(ds, ic, fmt.Sprintf("Could not find case #%d u_u", caseNumber))
+		return
+	}
+	if err != nil {
+		textRespond(ds, ic, "Couldn't update the case: "+err.Error())
+		return
+	}
+	textRespond(ds, ic, commandSuccessMessage)
+}
+
+// Internal functions
+
+func (c ModCase) ShortString() string {
+	str := fmt.Sprintf("`#%d` %s by <@%s> at <t:%d>", c.CaseNumber, c.Action, c.ActorID, c.CreatedAt.Unix())
+	if c.DurationSeconds > 0 {
+		str += " for " + humanDurationString(c.Duration())
+	}
+	if c.Source != "" {
+		str += fmt.Sprintf(" (%s)", c.Source)
+	}
+	if c.Reason != "" {
+		str += fmt.Sprintf(", reason: '%s'", c.Reason)
+	}
+	return str
+}
+
+func modCaseEmbed(c ModCase) *discordgo.MessageEmbed {
+	reason := c.Reason
+	if reason == "" {
+		reason = "No reason given"
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Case #%d: %s", c.CaseNumber, c.Action),
+		Color: colorYellow,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Target", Value: fmt.Sprintf("<@%s>", c.TargetID), Inline: true},
+			{Name: "Actor", Value: fmt.Sprintf("<@%s>", c.ActorID), Inline: true},
+			{Name: "Date", Value: fmt.Sprintf("<t:%d>", c.CreatedAt.Unix()), Inline: true},
+			{Name: "Reason", Value: truncateString(reason, embedFieldValueMaxLength)},
+		},
+	}
+	if c.DurationSeconds > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Duration", Value: humanDurationString(c.Duration()), Inline: true})
+	}
+	if c.Source != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Source", Value: c.Source, Inline: true})
+	}
+	if c.UpdatedAt != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Reason amended",
+			Value: fmt.Sprintf("By <@%s> at <t:%d>", c.UpdatedByID.String, c.UpdatedAt.Unix()),
+		})
+	}
+	return embed
+}
+
+// recordModCase stores the case, errors are notified to the guild since the action itself already happened
+// Returns the case number, or 0 if it could not be stored
+func recordModCase(ds *discordgo.Session, c ModCase) int {
+	caseNumber, err := moddingDS.addModCase(c)
+	serverNotifyIfErr("recordModCase", err, c.GuildID, ds)
+	return caseNumber
+}
+
+// sendToShadowRealm applies the timeout role and/or the native timeout, depending on the guild's timeout mode,
+// and records the case. An empty roleID skips the role. Returns the case number
+// If only one of them could be applied, the other error is notified to the guild and noted in the case
+func sendToShadowRealm(ds *discordgo.Session, guildID, userID, roleID string, duration time.Duration, actorID, source, reason string) (int, error) {
+	var roleErr, nativeErr error
+	roleApplied, nativeApplied := false, false
+	if roleID != "" {
+		if roleErr = ds.GuildMemberRoleAdd(guildID, userID, roleID); roleErr == nil {
+			removeShadowRealmRoleAfterDuration(guildID, userID, roleID, duration)
+			roleApplied = true
+		}
+	}
+	if getTimeoutMode(guildID) != timeoutModeRole {
+		until := time.Now().Add(min(duration, nativeTimeoutMaxDuration))
+		if nativeErr = ds.GuildMemberTimeout(guildID, userID, &until); nativeErr == nil {
+			nativeApplied = true
+		}
+	}
+	if !roleApplied && !nativeApplied {
+		err := errors.Join(roleErr, nativeErr)
+		if err == nil {
+			err = errors.New("no timeout role or native timeout to apply")
+		}
+		return 0, err
+	}
+
+	if roleErr != nil {
+		serverNotifyIfErr(fmt.Sprintf("Only the native timeout was applied to <@%s>, the timeout role", userID), roleErr, guildID, ds)
+		reason = strings.TrimSpace(reason + " (the timeout role could not be applied)")
+	}
+	if nativeErr != nil {
+		serverNotifyIfErr(fmt.Sprintf("Only the timeout role was applied to <@%s>, the native timeout", userID), nativeErr, guildID, ds)
+		reason = strings.TrimSpace(reason + " (the native timeout could not be applied)")
+	}
+
+	return recordModCase(ds, ModCase{
+		GuildID:         guildID,
+		ActorID:         actorID,
+		TargetID:        userID,
+		Action:          caseActionShadowRealm,
+		Reason:          reason,
+		DurationSeconds: int(duration.Seconds()),
+		Source:          source,
+	}), nil
+}
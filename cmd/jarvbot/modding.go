@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/csv"
 	"fmt"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,14 +28,24 @@ func onMessageDeleted(ctx context.Context) func(ds *discordgo.Session, mc *disco
 			}
 		}()
 
-		if mc.BeforeDelete != nil && mc.BeforeDelete.Author != nil {
+		defer discardArchivedAttachments(ds, mc.GuildID, mc.ID)
+
+		before := mc.BeforeDelete
+		if before == nil {
+			before = messageFromCache(mc.ID)
+		}
+		if before != nil {
+			go moddingDS.removeCachedMessage(mc.ID)
+		}
+
+		if before != nil && before.Author != nil {
 			// dont mind if bot messages get deleted
-			if mc.BeforeDelete.Author.Bot {
+			if before.Author.Bot || isMessageLogIgnored(ds, before) {
 				return
 			}
 
-			logsChannelID, err := serverDS.getServerProperty(mc.GuildID, serverPropMessageLogs)
-			if err != nil {
+			logsChannelID := logChannelID(mc.GuildID, logCategoryMessages)
+			if logsChannelID == "" {
 				return
 			}
 
@@ -40,14 +53,15 @@ func onMessageDeleted(ctx context.Context) func(ds *discordgo.Session, mc *disco
 				logsChannelID,
 				&discordgo.MessageEmbed{
 					Author: &discordgo.MessageEmbedAuthor{
-						Name:    mc.BeforeDelete.Author.Username,
-						IconURL: mc.BeforeDelete.Author.AvatarURL(""),
+						Name:    before.Author.Username,
+						IconURL: before.Author.AvatarURL(""),
 					},
 					Color:       colorRed,
 					Title:       "Message deleted",
-					Description: messageToString(mc.BeforeDelete),
+					Description: messageToString(redactedMessageCopy(before)),
 				},
 			)
+			sendArchivedAttachments(ds, mc.GuildID, logsChannelID, mc.ID)
 		}
 	}
 }
@@ -60,48 +74,175 @@ func onMessageUpdated(ctx context.Context) func(ds *discordgo.Session, mc *disco
 			}
 		}()
 
-		if mc != nil && mc.BeforeUpdate != nil && mc.Author != nil {
-			// dont mind if bot messages get updated
-			if mc.Author.Bot {
-				return
-			}
+		if mc == nil || mc.Message == nil || mc.Author == nil {
+			return
+		}
+		// dont mind if bot messages get updated
+		if mc.Author.Bot {
+			return
+		}
 
-			logsChannelID, err := serverDS.getServerProperty(mc.GuildID, serverPropMessageLogs)
-			if err != nil {
-				return
-			}
+		before := mc.BeforeUpdate
+		if before == nil {
+			before = messageFromCache(mc.ID)
+		}
+		// partial updates, like the ones when discord adds the embeds of links, have no content and would erase the cached one
+		if mc.Content != "" && shouldCacheMessage(mc.Message) {
+			go cacheGuildMessage(ds, mc.Message)
+		}
+		if before == nil {
+			return
+		}
 
-			if mc.BeforeUpdate.Content == mc.Message.Content {
-				return
-			}
+		logsChannelID := logChannelID(mc.GuildID, logCategoryMessages)
+		if logsChannelID == "" {
+			return
+		}
 
-			ds.ChannelMessageSendEmbed(
-				logsChannelID,
-				&discordgo.MessageEmbed{
-					Author: &discordgo.MessageEmbedAuthor{
-						Name:    mc.Author.Username,
-						IconURL: mc.Author.AvatarURL(""),
-					},
-					Color:       colorYellow,
-					Title:       "Message edited",
-					Description: messageUpdatedToString(mc.BeforeUpdate, mc.Message),
+		if !isSignificantEdit(mc.GuildID, before.Content, mc.Message.Content) || isMessageLogIgnored(ds, mc.Message) {
+			return
+		}
+
+		ds.ChannelMessageSendEmbed(
+			logsChannelID,
+			&discordgo.MessageEmbed{
+				Author: &discordgo.MessageEmbedAuthor{
+					Name:    mc.Author.Username,
+					IconURL: mc.Author.AvatarURL(""),
 				},
-			)
+				Color:       colorYellow,
+				Title:       "Message edited",
+				Description: messageUpdatedToString(redactedMessageCopy(before), redactedMessageCopy(mc.Message)),
+			},
+		)
+	}
+}
+
+func onGuildMemberAdd(ctx context.Context) func(ds *discordgo.Session, ma *discordgo.GuildMemberAdd) {
+	return func(ds *discordgo.Session, ma *discordgo.GuildMemberAdd) {
+		defer func() {
+			if r := recover(); r != nil {
+				adminNotifyIfErr("onGuildMemberAdd", fmt.Errorf("panic in onGuildMemberAdd: %s\n%s", r, string(debug.Stack())), ds)
+			}
+		}()
+
+		if ma.Member == nil || ma.User == nil {
+			return
+		}
+		logMemberJoin(ds, ma.GuildID, ma.User)
+		restoreStickyRoles(ds, ma.GuildID, ma.User.ID)
+		checkRaid(ds, ma.GuildID)
+		// raiders don't get the auto-roles, which could undo the quarantine, nor a welcome ping each
+		if quarantineIfLockedDown(ds, ma.GuildID, ma.User.ID) {
+			return
+		}
+		welcomeMember(ds, ma.GuildID, ma.Member)
+	}
+}
+
+// restoreStickyRoles adds back the roles that have a pending REMOVE_ROLE action, so leaving and rejoining
+// does not end the punishment early. The pending action still removes the role when it's due
+func restoreStickyRoles(ds *discordgo.Session, guildID, userID string) {
+	actions, err := userShadowRealmActions(guildID, userID)
+	if err != nil {
+		serverNotifyIfErr("restoreStickyRoles", err, guildID, ds)
+		return
+	}
+
+	restored := map[string]time.Time{}
+	for _, a := range actions {
+		split := strings.Split(a.ActionData, ";")
+		if len(split) != 2 || a.ScheduledFor.Before(time.Now()) {
+			continue
+		}
+		roleID := split[1]
+		if err := ds.GuildMemberRoleAdd(guildID, userID, roleID); err != nil {
+			serverNotifyIfErr(fmt.Sprintf("Couldn't restore role <@&%s> to user <@%s>", roleID, userID), err, guildID, ds)
+			continue
+		}
+		if a.ScheduledFor.After(restored[roleID]) {
+			restored[roleID] = a.ScheduledFor
+		}
+	}
+	if len(restored) == 0 {
+		return
+	}
+
+	var description strings.Builder
+	description.WriteString(fmt.Sprintf("User: <@%s>\nRestored roles:", userID))
+	for roleID, until := range restored {
+		description.WriteString(fmt.Sprintf("\n<@&%s> until <t:%d:R>", roleID, until.Unix()))
+		recordModCase(ds, ModCase{
+			GuildID:         guildID,
+			ActorID:         ds.State.User.ID,
+			TargetID:        userID,
+			Action:          caseActionShadowRealm,
+			Reason:          "Left and rejoined while punished",
+			DurationSeconds: int(time.Until(until).Seconds()),
+			Source:          caseSourceRejoin,
+		})
+	}
+	sendModLog(ds, guildID, &discordgo.MessageEmbed{
+		Title:       "Punishment evasion attempt",
+		Color:       colorRed,
+		Description: description.String(),
+	})
+}
+
+// a role's permissions affect who has the "Administrator" permission, so every cached staff level of the guild is dropped
+func onGuildRoleUpdate(ctx context.Context) func(ds *discordgo.Session, ru *discordgo.GuildRoleUpdate) {
+	return func(ds *discordgo.Session, ru *discordgo.GuildRoleUpdate) {
+		invalidateGuildStaffCache(ru.GuildID)
+	}
+}
+
+func onGuildRoleDelete(ctx context.Context) func(ds *discordgo.Session, rd *discordgo.GuildRoleDelete) {
+	return func(ds *discordgo.Session, rd *discordgo.GuildRoleDelete) {
+		invalidateGuildStaffCache(rd.GuildID)
+		for _, prop := range []string{serverPropModRoles, serverPropHelperRoles} {
+			contained, err := serverDS.ListPropertyContains(rd.GuildID, prop, rd.RoleID, serverPropListSeparator)
+			if err == nil && contained {
+				err = serverDS.RemoveFromListProperty(rd.GuildID, prop, rd.RoleID, serverPropListSeparator)
+			}
+			serverNotifyIfErr("onGuildRoleDelete", err, rd.GuildID, ds)
 		}
 	}
 }
 
 type UserWarning struct {
-	ID         int       `db:"UserWarning"`
-	UserID     string    `db:"DiscordUserID"`
-	WarnedByID string    `db:"WarnedByID"`
-	GuildID    string    `db:"GuildID"`
-	Reason     string    `db:"Reason"`
-	CreatedAt  time.Time `db:"CreatedAt"`
+	ID           int            `db:"UserWarning"`
+	UserID       string         `db:"DiscordUserID"`
+	WarnedByID   string         `db:"WarnedByID"`
+	GuildID      string         `db:"GuildID"`
+	Reason       string         `db:"Reason"`
+	Severity     int            `db:"Severity"`
+	ExpiresAt    *time.Time     `db:"ExpiresAt"`
+	PardonedAt   *time.Time     `db:"PardonedAt"`
+	PardonedByID sql.NullString `db:"PardonedByID"`
+	CreatedAt    time.Time      `db:"CreatedAt"`
+}
+
+func (u UserWarning) Status() string {
+	if u.PardonedAt != nil {
+		return "pardoned"
+	}
+	if u.ExpiresAt != nil && u.ExpiresAt.Before(time.Now()) {
+		return "expired"
+	}
+	return "active"
 }
 
 func (u UserWarning) ShortString() string {
-	return fmt.Sprintf("By <@%s> at <t:%d>, reason: '%s'", u.WarnedByID, u.CreatedAt.Unix(), u.Reason)
+	str := fmt.Sprintf("`#%d` [severity %d] By <@%s> at <t:%d>, reason: '%s'", u.ID, u.Severity, u.WarnedByID, u.CreatedAt.Unix(), u.Reason)
+	switch {
+	case u.PardonedAt != nil:
+		str += fmt.Sprintf(" (pardoned by <@%s> <t:%d:R>)", u.PardonedByID.String, u.PardonedAt.Unix())
+	case u.ExpiresAt != nil && u.ExpiresAt.Before(time.Now()):
+		str += fmt.Sprintf(" (expired <t:%d:R>)", u.ExpiresAt.Unix())
+	case u.ExpiresAt != nil:
+		str += fmt.Sprintf(" (expires <t:%d:R>)", u.ExpiresAt.Unix())
+	}
+	return str
 }
 
 // Command Answers
@@ -124,6 +265,7 @@ func answerAddMod(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx contex
 		ds.ChannelMessageSend(mc.ChannelID, "Could not add the mod: "+err.Error())
 		return false
 	}
+	invalidateStaffCache(mc.GuildID, match[1])
 	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
 	return true
 }
@@ -151,6 +293,7 @@ func answerRemoveMod(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx con
 		ds.ChannelMessageSend(mc.ChannelID, "Could not remove the mod: "+err.Error())
 		return false
 	}
+	invalidateStaffCache(mc.GuildID, targetID)
 
 	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
 	return true
@@ -162,22 +305,69 @@ func answerCheckMods(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx con
 		ds.ChannelMessageSend(mc.ChannelID, "Error reading mod list: "+err.Error())
 		return false
 	}
+	modRoles, err := serverDS.GetListProperty(mc.GuildID, serverPropModRoles, serverPropListSeparator)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Error reading mod roles: "+err.Error())
+		return false
+	}
+	helperRoles, err := serverDS.GetListProperty(mc.GuildID, serverPropHelperRoles, serverPropListSeparator)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Error reading helper roles: "+err.Error())
+		return false
+	}
 
-	if len(current) == 0 {
+	if len(current) == 0 && len(modRoles) == 0 && len(helperRoles) == 0 {
 		ds.ChannelMessageSend(mc.ChannelID, "No mods configured! Only users with the 'Administrator' role can configure me here :3c")
 		return true
 	}
 
 	var b strings.Builder
-	b.WriteString("Configured Server Mods:\n")
-	for _, id := range current {
-		b.WriteString("<@" + id + ">\n")
+	if len(current) > 0 {
+		b.WriteString("Configured Server Mods:\n")
+		for _, id := range current {
+			b.WriteString("<@" + id + ">\n")
+		}
+	}
+	if len(modRoles) > 0 {
+		b.WriteString("Mod Roles:\n")
+		for _, id := range modRoles {
+			b.WriteString("<@&" + id + ">\n")
+		}
+	}
+	if len(helperRoles) > 0 {
+		b.WriteString("Helper Roles:\n")
+		for _, id := range helperRoles {
+			b.WriteString("<@&" + id + ">\n")
+		}
 	}
 	b.WriteString("And any user with the 'Administrator' Discord Server permission.\n")
-	ds.ChannelMessageSend(mc.ChannelID, b.String())
+	ds.ChannelMessageSendComplex(mc.ChannelID, &discordgo.MessageSend{
+		Content:         b.String(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
 	return true
 }
 
+// Format: !addmodrole @role
+func answerAddModRole(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	return addStaffRole(ds, mc, serverPropModRoles)
+}
+
+// Format: !removemodrole @role
+func answerRemoveModRole(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	return removeStaffRole(ds, mc, serverPropModRoles)
+}
+
+// Format: !addhelperrole @role
+func answerAddHelperRole(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	return addStaffRole(ds, mc, serverPropHelperRoles)
+}
+
+// Format: !removehelperrole @role
+func answerRemoveHelperRole(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	return removeStaffRole(ds, mc, serverPropHelperRoles)
+}
+
 func answerRoleIDs(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
 	roles, err := ds.GuildRoles(mc.GuildID)
 	adminNotifyIfErr("answerRoleIDs", err, ds)
@@ -204,47 +394,303 @@ func answerWarn(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
 		return
 	}
 
-	user := ic.ApplicationCommandData().Options[0].UserValue(ds)
-	message := ic.ApplicationCommandData().Options[1].StringValue()
-	ping := ic.ApplicationCommandData().Options[2].BoolValue()
-	err = moddingDS.warnUser(user.ID, interactionUser(ic).ID, ic.GuildID, message)
+	options := optionMap(ic.ApplicationCommandData().Options)
+	user := options["user"].UserValue(ds)
+	message := options["reason"].StringValue()
+	ping := optionBoolValueOrFalse(options["ping"])
+	severity := max(optionIntValueOrZero(options["severity"]), int(warnSeverityMin))
+
+	var expiresAt *time.Time
+	if opt, ok := options["expires_in"]; ok {
+		expiresIn := stringToDuration(opt.StringValue())
+		if expiresIn <= 0 {
+			textRespond(ds, ic, "Invalid expires_in value, use something like 30d or 12h")
+			return
+		}
+		t := time.Now().Add(expiresIn)
+		expiresAt = &t
+	}
+
+	warningID, err := moddingDS.warnUser(user.ID, interactionUser(ic).ID, ic.GuildID, message, severity, expiresAt)
 	if err != nil {
 		textRespond(ds, ic, "There was an error storing the warning: "+err.Error())
 		return
 	}
 
+	expiresIn := 0
+	if expiresAt != nil {
+		expiresIn = int(time.Until(*expiresAt).Seconds())
+	}
+	caseNumber := recordModCase(ds, ModCase{
+		GuildID:         ic.GuildID,
+		ActorID:         interactionUser(ic).ID,
+		TargetID:        user.ID,
+		Action:          caseActionWarn,
+		Reason:          message,
+		DurationSeconds: expiresIn,
+		Source:          caseSourceCommand,
+	})
+
+	// the warning is sent before the policies, a kicked or banned user shares no server with the bot
+	var dmErr error
 	if ping {
 		formattedWarningMessage := fmt.Sprintf("**You have been warned in %s server** for the following reason:\n*%s*", g.Name, message)
-		_, err = sendDirectMessage(user.ID, formattedWarningMessage, ds)
-		if err != nil {
-			textRespond(ds, ic, "Warning recorded, but couldn't send the warning to the user: "+err.Error())
-			return
-		}
+		_, dmErr = sendDirectMessage(user.ID, formattedWarningMessage, ds)
+	}
+
+	sanctionMsg := ""
+	policy, err := applyWarnPolicies(ds, ic.GuildID, user.ID)
+	serverNotifyIfErr("answerWarn::applyWarnPolicies", err, ic.GuildID, ds)
+	if policy != nil {
+		sanctionMsg = fmt.Sprintf("\nAutomatic sanction applied: %s", policy)
 	}
 
-	textRespond(ds, ic, fmt.Sprintf("The user %s#%s has been warned. Reason: '%s'", user.Username, user.Discriminator, message))
+	if dmErr != nil {
+		textRespond(ds, ic, "Warning recorded, but couldn't send the warning to the user: "+dmErr.Error()+sanctionMsg)
+		return
+	}
+
+	textRespond(ds, ic, fmt.Sprintf("The user %s#%s has been warned (warning #%d, case #%d). Reason: '%s'%s", user.Username, user.Discriminator, warningID, caseNumber, message, sanctionMsg))
 }
 
 func answerWarnings(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
-	user := ic.ApplicationCommandData().Options[0].UserValue(ds)
-	warnings, err := moddingDS.userWarnings(user.ID, ic.GuildID)
+	options := optionMap(ic.ApplicationCommandData().Options)
+	user := options["user"].UserValue(ds)
+	filter := warningFilterActive
+	if opt, ok := options["filter"]; ok {
+		filter = opt.StringValue()
+	}
+	page := max(optionIntValueOrZero(options["page"]), 1)
+
+	warnings, total, err := moddingDS.filteredUserWarnings(user.ID, ic.GuildID, filter, page-1, warningsPageSize)
 	if err != nil {
 		textRespond(ds, ic, "Couldn't get the user warnings: "+err.Error())
 		return
 	}
 
-	responseMsg := fmt.Sprintf("%s has been warned %d times:\n", user.Mention(), len(warnings))
+	filterName := filter
+	if filter == warningFilterAll {
+		filterName = "total"
+	}
+	pageCount := max((total+warningsPageSize-1)/warningsPageSize, 1)
+	responseMsg := fmt.Sprintf("%s has %d %s warnings (page %d/%d):\n", user.Mention(), total, filterName, page, pageCount)
 	for _, warning := range warnings {
 		responseMsg += warning.ShortString() + "\n"
 	}
 
+	// the notes are not paginated, so they are only shown with the first page
+	// they are private, so the answer is ephemeral when it includes them
+	private := false
+	if page == 1 {
+		notes, err := moddingDS.userModNotes(ic.GuildID, user.ID)
+		if err != nil {
+			textRespond(ds, ic, "Couldn't get the user notes: "+err.Error())
+			return
+		}
+		if len(notes) > 0 {
+			responseMsg += fmt.Sprintf("\nAnd %d mod notes:\n%s", len(notes), modNotesString(notes))
+			private = true
+		}
+	}
+
+	fileName := fmt.Sprintf("%s_warnings.txt", user.Username)
+	switch {
+	case private && len(responseMsg) < discordMaxMessageLength:
+		ephemeralRespond(ds, ic, responseMsg)
+	case private:
+		ephemeralFileRespond(ds, ic, "Damn that user has been warned a lot", fileName, responseMsg)
+	case len(responseMsg) < discordMaxMessageLength:
+		textRespond(ds, ic, responseMsg)
+	default:
+		interactionFileRespond(ds, ic, "Damn that user has been warned a lot", fileName, responseMsg)
+	}
+}
+
+func answerWarning(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	subcommand := ic.ApplicationCommandData().Options[0]
+	options := optionMap(subcommand.Options)
+	modID := interactionUser(ic).ID
+	warningID := optionIntValueOrZero(options["id"])
+
+	switch subcommand.Name {
+	case "history":
+		answerWarningHistory(ds, ic, warningID)
+		return
+	case "export":
+		answerExportWarnings(ds, ic)
+		return
+	}
+
+	warning, err := moddingDS.userWarning(warningID, ic.GuildID)
+	if err == nil {
+		switch subcommand.Name {
+		case "pardon":
+			err = moddingDS.pardonUserWarning(warningID, ic.GuildID, modID)
+		case "remove":
+			err = moddingDS.removeUserWarning(warningID, ic.GuildID, modID)
+		case "edit":
+			err = moddingDS.editUserWarningReason(warningID, ic.GuildID, modID, options["reason"].StringValue())
+		}
+	}
+
+	switch err {
+	case nil:
+		caseActions := map[string]string{"pardon": caseActionWarnPardon, "remove": caseActionWarnRemove}
+		if action, ok := caseActions[subcommand.Name]; ok {
+			recordModCase(ds, ModCase{
+				GuildID:  ic.GuildID,
+				ActorID:  modID,
+				TargetID: warning.UserID,
+				Action:   action,
+				Reason:   fmt.Sprintf("Warning #%d: %s", warningID, warning.Reason),
+				Source:   caseSourceCommand,
+			})
+		}
+		textRespond(ds, ic, commandSuccessMessage)
+		sendModLog(ds, ic.GuildID, &discordgo.MessageEmbed{
+			Title:       "Warning " + subcommand.Name,
+			Color:       colorYellow,
+			Description: fmt.Sprintf("Warning: #%d\nUser: <@%s>\nBy: <@%s>", warningID, warning.UserID, modID),
+		})
+	case sql.ErrNoRows, errZeroRowsAffected:
+		textRespond(ds, ic, fmt.Sprintf("Could not find warning #%d, or it was already pardoned u_u", warningID))
+	default:
+		textRespond(ds, ic, "Error: "+err.Error())
+	}
+}
+
+func answerWarningHistory(ds *discordgo.Session, ic *discordgo.InteractionCreate, warningID int) {
+	audits, err := moddingDS.userWarningAudits(warningID, ic.GuildID)
+	if err != nil {
+		textRespond(ds, ic, "Couldn't get the warning history: "+err.Error())
+		return
+	}
+	if len(audits) == 0 {
+		textRespond(ds, ic, fmt.Sprintf("There is no history for warning #%d", warningID))
+		return
+	}
+
+	responseMsg := fmt.Sprintf("History of warning #%d:\n", warningID)
+	for _, audit := range audits {
+		responseMsg += fmt.Sprintf("<t:%d> %s by <@%s>", audit.CreatedAt.Unix(), audit.Action, audit.ActorID)
+		if audit.Details != "" {
+			responseMsg += ": " + audit.Details
+		}
+		responseMsg += "\n"
+	}
+
 	if len(responseMsg) < discordMaxMessageLength {
 		textRespond(ds, ic, responseMsg)
 	} else {
-		interactionFileRespond(ds, ic, "Damn that user has been warned a lot", fmt.Sprintf("%s_warnings.txt", user.Username), responseMsg)
+		interactionFileRespond(ds, ic, "That warning has a long history", fmt.Sprintf("warning_%d_history.txt", warningID), responseMsg)
 	}
 }
 
+func answerExportWarnings(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	warnings, err := moddingDS.guildWarnings(ic.GuildID)
+	if err != nil {
+		textRespond(ds, ic, "Couldn't get the warnings: "+err.Error())
+		return
+	}
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"ID", "UserID", "WarnedByID", "Reason", "Severity", "Status", "CreatedAt", "ExpiresAt", "PardonedAt", "PardonedByID"})
+	for _, warning := range warnings {
+		w.Write([]string{
+			strconv.Itoa(warning.ID),
+			warning.UserID,
+			warning.WarnedByID,
+			warning.Reason,
+			strconv.Itoa(warning.Severity),
+			warning.Status(),
+			warning.CreatedAt.UTC().Format(time.RFC3339),
+			formatOptionalTime(warning.ExpiresAt),
+			formatOptionalTime(warning.PardonedAt),
+			warning.PardonedByID.String,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		textRespond(ds, ic, "Couldn't export the warnings: "+err.Error())
+		return
+	}
+
+	interactionFileRespond(ds, ic, fmt.Sprintf("Exported %d warnings", len(warnings)), "warnings.csv", b.String())
+}
+
+func addStaffRole(ds *discordgo.Session, mc *discordgo.MessageCreate, prop string) bool {
+	roleIDs := extractDiscordIDs(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(roleIDs) != 1 {
+		ds.ChannelMessageSend(mc.ChannelID, "Please mention exactly one role, or use its ID")
+		return false
+	}
+	roleID := roleIDs[0]
+	if roleID == mc.GuildID {
+		ds.ChannelMessageSend(mc.ChannelID, "Everyone can't be staff, silly :3")
+		return false
+	}
+	if !guildHasRole(ds, mc.GuildID, roleID) {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not find that role in this server")
+		return false
+	}
+
+	current, _ := serverDS.GetListProperty(mc.GuildID, prop, serverPropListSeparator)
+	if len(current) >= maxServerStaffRoles {
+		ds.ChannelMessageSend(mc.ChannelID, "Too many staff roles!, please clean up before adding more :3")
+		return false
+	}
+
+	err := serverDS.AddToListProperty(mc.GuildID, prop, roleID, serverPropListSeparator)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not add the role: "+err.Error())
+		return false
+	}
+	invalidateGuildStaffCache(mc.GuildID)
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+func removeStaffRole(ds *discordgo.Session, mc *discordgo.MessageCreate, prop string) bool {
+	roleIDs := extractDiscordIDs(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	if len(roleIDs) != 1 {
+		ds.ChannelMessageSend(mc.ChannelID, "Please mention exactly one role, or use its ID")
+		return false
+	}
+	roleID := roleIDs[0]
+
+	contained, err := serverDS.ListPropertyContains(mc.GuildID, prop, roleID, serverPropListSeparator)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not check the staff roles: "+err.Error())
+		return false
+	}
+	if !contained {
+		ds.ChannelMessageSend(mc.ChannelID, "That role is not a staff role :3")
+		return false
+	}
+
+	err = serverDS.RemoveFromListProperty(mc.GuildID, prop, roleID, serverPropListSeparator)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not remove the role: "+err.Error())
+		return false
+	}
+	invalidateGuildStaffCache(mc.GuildID)
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sendModLog sends the embed to the guild's moderation logs channel, if it has one
+func sendModLog(ds *discordgo.Session, guildID string, embed *discordgo.MessageEmbed) {
+	sendLog(ds, guildID, logCategoryModeration, embed)
+}
+
 func messageToString(m *discordgo.Message) string {
 	str := "In channel: <#" + m.ChannelID + ">"
 	if m.Author != nil {
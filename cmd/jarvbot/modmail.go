@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	buttonReducerMap["modmailguild"] = handleModmailGuildBtn
+}
+
+var errModmailUnavailable = errors.New("that server is not accepting modmail")
+
+// opening threads is serialized so a user sending several DMs at once only gets one thread per guild
+var modmailMutex sync.Mutex
+
+// Command Answers
+
+func answerModmailHere(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	err := serverDS.setServerProperty(mc.GuildID, serverPropModmailChannel, mc.ChannelID)
+	serverNotifyIfErr("answerModmailHere", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Okay! Will open a thread in this channel for every user that DMs me, make sure only the staff can see it :3")
+	}
+	return err == nil
+}
+
+func answerModmailStop(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	err := serverDS.setServerProperty(mc.GuildID, serverPropModmailChannel, "")
+	serverNotifyIfErr("answerModmailStop", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Okay! Will not accept new modmail, the open threads can still be answered and closed")
+	}
+	return err == nil
+}
+
+// Format: !reply message
+func answerModmailReply(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	return replyModmail(ds, mc, false)
+}
+
+// Format: !areply message
+func answerModmailAnonymousReply(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	return replyModmail(ds, mc, true)
+}
+
+// Format: !close [reason]
+func answerModmailClose(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	thread, err := moddingDS.openModmailThreadByChannel(mc.ChannelID)
+	if err == sql.ErrNoRows {
+		ds.ChannelMessageSend(mc.ChannelID, "This is not an open modmail thread :3")
+		return false
+	}
+	if err != nil {
+		serverNotifyIfErr("answerModmailClose::openModmailThreadByChannel", err, mc.GuildID, ds)
+		return false
+	}
+	reason := strings.TrimSpace(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+
+	transcript, err := modmailTranscript(ds, thread.ThreadID)
+	if err != nil {
+		serverNotifyIfErr("answerModmailClose::modmailTranscript", err, mc.GuildID, ds)
+		ds.ChannelMessageSend(mc.ChannelID, "Could not read the thread to store its transcript u_u")
+		return false
+	}
+	err = moddingDS.closeModmailThread(thread.ID, mc.Author.ID, transcript)
+	if err == errZeroRowsAffected {
+		ds.ChannelMessageSend(mc.ChannelID, "This thread was already closed")
+		return false
+	}
+	if err != nil {
+		serverNotifyIfErr("answerModmailClose::closeModmailThread", err, mc.GuildID, ds)
+		return false
+	}
+
+	notice := fmt.Sprintf("Your conversation with the staff of **%s** has been closed", modmailGuildName(ds, thread.GuildID))
+	if reason != "" {
+		notice += ": " + reason
+	}
+	notice += "\nIf you need anything else, just DM me again :3"
+	if _, err := sendDirectMessage(thread.UserID, notice, ds); err != nil {
+		log.Println("answerModmailClose::sendDirectMessage:", err)
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Thread closed! Use `!modmailtranscript %d` to get its transcript", thread.ID))
+	archived, locked := true, true
+	_, err = ds.ChannelEditComplex(thread.ThreadID, &discordgo.ChannelEdit{Archived: &archived, Locked: &locked})
+	serverNotifyIfErr("answerModmailClose::ChannelEditComplex", err, mc.GuildID, ds)
+	return true
+}
+
+// Format: !modmailtranscript <thread number>
+func answerModmailTranscript(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	id, err := strconv.Atoi(strings.TrimSpace(commandPrefixRegex.ReplaceAllString(mc.Content, "")))
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !modmailtranscript <thread number>")
+		return false
+	}
+
+	thread, err := moddingDS.modmailThread(id, mc.GuildID)
+	if err == sql.ErrNoRows {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not find that thread u_u")
+		return false
+	}
+	if err != nil {
+		serverNotifyIfErr("answerModmailTranscript", err, mc.GuildID, ds)
+		return false
+	}
+	if thread.Status == modmailStatusOpen {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("That thread is still open: <#%s>", thread.ThreadID))
+		return false
+	}
+
+	_, err = ds.ChannelMessageSendComplex(mc.ChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("Transcript of the modmail thread #%d with <@%s>, closed by <@%s>", thread.ID, thread.UserID, thread.ClosedByID.String),
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("modmail_%d.txt", thread.ID),
+			ContentType: "text/plain",
+			Reader:      strings.NewReader(thread.Transcript.String),
+		}},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	serverNotifyIfErr("answerModmailTranscript::ChannelMessageSendComplex", err, mc.GuildID, ds)
+	return err == nil
+}
+
+// Format: !modmailhistory @user
+func answerModmailHistory(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	match := commandWithMention.FindStringSubmatch(mc.Content)
+	if match == nil || len(match) != 2 {
+		ds.ChannelMessageSend(mc.ChannelID, commandWithMentionError)
+		return false
+	}
+
+	threads, err := moddingDS.userModmailThreads(mc.GuildID, match[1])
+	if err != nil {
+		serverNotifyIfErr("answerModmailHistory", err, mc.GuildID, ds)
+		return false
+	}
+	if len(threads) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "That user never contacted the staff :3")
+		return true
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Modmail threads of <@%s>:", match[1]))
+	for _, t := range threads {
+		line := fmt.Sprintf("\n`#%d` opened <t:%d:R>, ", t.ID, t.CreatedAt.Unix())
+		if t.Status == modmailStatusOpen {
+			line += fmt.Sprintf("still open in <#%s>", t.ThreadID)
+		} else {
+			line += fmt.Sprintf("closed by <@%s>", t.ClosedByID.String)
+		}
+		if b.Len()+len(line) > discordMessageMaxLength {
+			break
+		}
+		b.WriteString(line)
+	}
+	ds.ChannelMessageSendComplex(mc.ChannelID, &discordgo.MessageSend{
+		Content:         b.String(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return true
+}
+
+// Button handlers
+
+func handleModmailGuildBtn(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if len(data) < 3 {
+		return fmt.Errorf("unexpected modmail button data: %v", data)
+	}
+	guildID, messageID := data[1], data[2]
+
+	m, err := ds.ChannelMessage(ic.ChannelID, messageID)
+	if err != nil {
+		ephemeralRespond(ds, ic, "Could not find your message, please send it again u_u")
+		return err
+	}
+
+	err = ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("Sending it to the staff of **%s**", modmailGuildName(ds, guildID)),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	relayModmailToGuild(ds, guildID, m)
+	return nil
+}
+
+// Internal functions
+
+// relayModmail forwards a DM to the user's modmail thread, opening one if needed.
+// Returns false if the user does not share any guild that accepts modmail
+func relayModmail(ds *discordgo.Session, m *discordgo.Message) bool {
+	threads, err := moddingDS.userOpenModmailThreads(m.Author.ID)
+	if err != nil {
+		adminNotifyIfErr("relayModmail::userOpenModmailThreads", err, ds)
+		return false
+	}
+	if len(threads) == 1 {
+		relayToModmailThread(ds, threads[0], m)
+		return true
+	}
+	if len(threads) > 1 {
+		guildIDs := make([]string, len(threads))
+		for i, t := range threads {
+			guildIDs[i] = t.GuildID
+		}
+		sendModmailGuildPicker(ds, m, guildIDs, "You are talking with the staff of several servers, which one is this message for?")
+		return true
+	}
+
+	guildIDs := modmailGuildIDs(ds, m.Author.ID)
+	switch len(guildIDs) {
+	case 0:
+		return false
+	case 1:
+		relayModmailToGuild(ds, guildIDs[0], m)
+	default:
+		sendModmailGuildPicker(ds, m, guildIDs, "Which server's staff do you want to contact?")
+	}
+	return true
+}
+
+// modmailGuildIDs returns the guilds that accept modmail and have the user as a member
+func modmailGuildIDs(ds *discordgo.Session, userID string) []string {
+	properties, err := serverDS.getServerProperties(serverPropModmailChannel)
+	if err != nil {
+		adminNotifyIfErr("modmailGuildIDs", err, ds)
+		return nil
+	}
+	var guildIDs []string
+	for _, p := range properties {
+		if p.PropertyValue != "" && isGuildMember(ds, p.ServerID, userID) {
+			guildIDs = append(guildIDs, p.ServerID)
+		}
+	}
+	return guildIDs
+}
+
+func sendModmailGuildPicker(ds *discordgo.Session, m *discordgo.Message, guildIDs []string, content string) {
+	var buttons []*discordgo.Button
+	for _, guildID := range guildIDs {
+		customID := "modmailguild" + buttonCustomIdSeparator + guildID + buttonCustomIdSeparator + m.ID
+		buttons = append(buttons, newButton(truncateString(modmailGuildName(ds, guildID), 70), discordgo.PrimaryButton, customID))
+	}
+	_, err := ds.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content:    content,
+		Components: *buildButtonComponents(buttons),
+	})
+	if err != nil {
+		log.Println("sendModmailGuildPicker:", err)
+	}
+}
+
+func relayModmailToGuild(ds *discordgo.Session, guildID string, m *discordgo.Message) {
+	thread, err := findOrOpenModmailThread(ds, guildID, m.Author)
+	if err == errModmailUnavailable {
+		ds.ChannelMessageSend(m.ChannelID, "Sowwy, that server is not accepting messages anymore u_u")
+		return
+	}
+	if err != nil {
+		serverNotifyIfErr("relayModmailToGuild::findOrOpenModmailThread", err, guildID, ds)
+		ds.ChannelMessageSend(m.ChannelID, "Could not contact the staff of that server u_u")
+		return
+	}
+	relayToModmailThread(ds, thread, m)
+}
+
+func findOrOpenModmailThread(ds *discordgo.Session, guildID string, user *discordgo.User) (ModmailThread, error) {
+	modmailMutex.Lock()
+	defer modmailMutex.Unlock()
+
+	threads, err := moddingDS.userOpenModmailThreads(user.ID)
+	if err != nil {
+		return ModmailThread{}, err
+	}
+	for _, t := range threads {
+		if t.GuildID == guildID {
+			return t, nil
+		}
+	}
+
+	channelID, _ := serverDS.getServerProperty(guildID, serverPropModmailChannel)
+	if channelID == "" || !isGuildMember(ds, guildID, user.ID) {
+		return ModmailThread{}, errModmailUnavailable
+	}
+
+	starter, err := ds.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title: "New modmail",
+			Color: colorBlue,
+			Description: fmt.Sprintf("From %s (%s)\n%s\nAnswer in the thread with !reply or !areply (anonymous), and use !close [reason] once done",
+				user.Mention(), user.Username, accountAgeString(user.ID)),
+		}},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		return ModmailThread{}, err
+	}
+	threadChannel, err := ds.MessageThreadStart(channelID, starter.ID, truncateString(user.Username, 90), modmailThreadArchiveMinutes)
+	if err != nil {
+		return ModmailThread{}, err
+	}
+	id, err := moddingDS.addModmailThread(guildID, user.ID, threadChannel.ID)
+	if err != nil {
+		return ModmailThread{}, err
+	}
+
+	sendDirectMessage(user.ID, fmt.Sprintf("You are now talking with the staff of **%s**! I will forward them everything you send me, and their answers will arrive here :3",
+		modmailGuildName(ds, guildID)), ds)
+	return ModmailThread{ID: id, GuildID: guildID, UserID: user.ID, ThreadID: threadChannel.ID, Status: modmailStatusOpen}, nil
+}
+
+func relayToModmailThread(ds *discordgo.Session, thread ModmailThread, m *discordgo.Message) {
+	files, links := downloadAttachmentFiles(m.Attachments)
+	content := fmt.Sprintf("**%s**: %s", m.Author.Username, m.Content)
+	for _, link := range links {
+		content += "\n" + link
+	}
+
+	_, err := ds.ChannelMessageSendComplex(thread.ThreadID, &discordgo.MessageSend{
+		Content:         truncateString(content, discordMessageMaxLength),
+		Files:           files,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if isUnknownChannelErr(err) {
+		// the staff deleted the thread instead of closing it
+		moddingDS.closeModmailThread(thread.ID, ds.State.User.ID, "")
+		relayModmailToGuild(ds, thread.GuildID, m)
+		return
+	}
+	if err != nil {
+		serverNotifyIfErr("relayToModmailThread", err, thread.GuildID, ds)
+		ds.ChannelMessageSend(m.ChannelID, "Could not forward your message to the staff u_u")
+		return
+	}
+	ds.MessageReactionAdd(m.ChannelID, m.ID, "✅")
+}
+
+func replyModmail(ds *discordgo.Session, mc *discordgo.MessageCreate, anonymous bool) bool {
+	thread, err := moddingDS.openModmailThreadByChannel(mc.ChannelID)
+	if err == sql.ErrNoRows {
+		ds.ChannelMessageSend(mc.ChannelID, "This is not an open modmail thread :3")
+		return false
+	}
+	if err != nil {
+		serverNotifyIfErr("replyModmail::openModmailThreadByChannel", err, mc.GuildID, ds)
+		return false
+	}
+	text := commandPrefixRegex.ReplaceAllString(mc.Content, "")
+	if strings.TrimSpace(text) == "" && len(mc.Attachments) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Format: !reply message (or !areply to answer anonymously)")
+		return false
+	}
+
+	guildName := modmailGuildName(ds, thread.GuildID)
+	signature := fmt.Sprintf("**Staff of %s**", guildName)
+	if !anonymous {
+		name := mc.Author.DisplayName()
+		if mc.Member != nil && mc.Member.Nick != "" {
+			name = mc.Member.Nick
+		}
+		signature = fmt.Sprintf("**%s** (staff of %s)", name, guildName)
+	}
+
+	userChannel, err := getUserChannel(thread.UserID, ds)
+	if err == nil {
+		files, links := downloadAttachmentFiles(mc.Attachments)
+		content := signature + ": " + text
+		for _, link := range links {
+			content += "\n" + link
+		}
+		_, err = ds.ChannelMessageSendComplex(userChannel.ID, &discordgo.MessageSend{
+			Content: truncateString(content, discordMessageMaxLength),
+			Files:   files,
+		})
+	}
+	if err != nil {
+		log.Println("replyModmail:", err)
+		ds.ChannelMessageSend(mc.ChannelID, "Could not DM the user, they might have left or closed their DMs u_u")
+		return false
+	}
+	ds.MessageReactionAdd(mc.ChannelID, mc.ID, "✅")
+	return true
+}
+
+// modmailTranscript reads the whole thread, oldest message first
+func modmailTranscript(ds *discordgo.Session, threadID string) (string, error) {
+	var messages []*discordgo.Message
+	beforeID := ""
+	for len(messages) < modmailTranscriptMaxMessages {
+		batch, err := ds.ChannelMessages(threadID, maxMessageCount, beforeID, "", "")
+		if err != nil {
+			return "", err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		messages = append(messages, batch...)
+		beforeID = batch[len(batch)-1].ID
+	}
+
+	var b strings.Builder
+	for i := len(messages) - 1; i >= 0; i-- {
+		b.WriteString(transcriptLine(messages[i]))
+	}
+	return b.String(), nil
+}
+
+func modmailGuildName(ds *discordgo.Session, guildID string) string {
+	if g, err := ds.State.Guild(guildID); err == nil {
+		return g.Name
+	}
+	return guildID
+}
+
+// downloadAttachmentFiles downloads the attachments so they can be reuploaded,
+// the ones that don't fit in a single message are returned as links instead
+func downloadAttachmentFiles(attachments []*discordgo.MessageAttachment) ([]*discordgo.File, []string) {
+	var files []*discordgo.File
+	var links []string
+	var totalSize int64
+	for _, a := range attachments {
+		size := int64(a.Size)
+		if len(files) == discordMaxFilesPerMessage || totalSize+size > discordMaxUploadSize {
+			links = append(links, a.URL)
+			continue
+		}
+		data, err := downloadAttachment(a.URL, size)
+		if err != nil {
+			log.Println("downloadAttachmentFiles:", err)
+			links = append(links, a.URL)
+			continue
+		}
+		files = append(files, &discordgo.File{Name: a.Filename, ContentType: a.ContentType, Reader: bytes.NewReader(data)})
+		totalSize += size
+	}
+	return files, links
+}
+
+func downloadAttachment(url string, maxSize int64) ([]byte, error) {
+	resp, err := attachmentDownloadClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download the attachment, status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSize))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	buttonReducerMap["modnotemodal"] = handleModNoteModal
+}
+
+// Slash Command answers
+
+// notes are private, so every answer is ephemeral
+func answerNote(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	subcommand := ic.ApplicationCommandData().Options[0]
+	options := optionMap(subcommand.Options)
+
+	switch subcommand.Name {
+	case "add":
+		user := options["user"].UserValue(ds)
+		ephemeralRespond(ds, ic, addModNote(ic.GuildID, user.ID, interactionUser(ic).ID, options["content"].StringValue()))
+	case "list":
+		user := options["user"].UserValue(ds)
+		notes, err := moddingDS.userModNotes(ic.GuildID, user.ID)
+		if err != nil {
+			ephemeralRespond(ds, ic, "Couldn't get the notes: "+err.Error())
+			return
+		}
+		if len(notes) == 0 {
+			ephemeralRespond(ds, ic, fmt.Sprintf("%s has no mod notes :3", user.Mention()))
+			return
+		}
+		responseMsg := fmt.Sprintf("%s has %d mod notes:\n%s", user.Mention(), len(notes), modNotesString(notes))
+		if len(responseMsg) < discordMaxMessageLength {
+			ephemeralRespond(ds, ic, responseMsg)
+		} else {
+			ephemeralFileRespond(ds, ic, "That's a lot of notes", fmt.Sprintf("%s_notes.txt", user.Username), responseMsg)
+		}
+	case "remove":
+		err := moddingDS.removeModNote(optionIntValueOrZero(options["id"]), ic.GuildID)
+		if err == errZeroRowsAffected {
+			ephemeralRespond(ds, ic, "Could not find that note u_u")
+			return
+		}
+		if err != nil {
+			ephemeralRespond(ds, ic, "Couldn't remove the note: "+err.Error())
+			return
+		}
+		ephemeralRespond(ds, ic, commandSuccessMessage)
+	}
+}
+
+// answerAddModNote is the user context menu command, it opens a modal to write the note
+func answerAddModNote(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	data := ic.ApplicationCommandData()
+	user, ok := data.Resolved.Users[data.TargetID]
+	username := data.TargetID
+	if ok {
+		username = user.Username
+	}
+
+	err := ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: "modnotemodal" + buttonCustomIdSeparator + data.TargetID,
+			Title:    truncateString("Mod note for "+username, 40),
+			Components: []discordgo.MessageComponent{
+				modalTextInput("content", "Note", discordgo.TextInputParagraph, "", modNoteMaxLength),
+			},
+		},
+	})
+	serverNotifyIfErr("answerAddModNote::InteractionRespond", err, ic.GuildID, ds)
+}
+
+// Modal handlers
+
+func handleModNoteModal(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if len(data) < 2 {
+		return fmt.Errorf("unexpected mod note modal data: %v", data)
+	}
+	content := modalTextValues(ic)["content"]
+	if content == "" {
+		return ephemeralRespond(ds, ic, "The note can't be empty :<")
+	}
+	return ephemeralRespond(ds, ic, addModNote(ic.GuildID, data[1], interactionUser(ic).ID, content))
+}
+
+// Internal functions
+
+// addModNote stores the note and returns the message to show to the mod
+func addModNote(guildID, userID, authorID, content string) string {
+	notes, err := moddingDS.userModNotes(guildID, userID)
+	if err != nil {
+		return "Couldn't check the current notes: " + err.Error()
+	}
+	if len(notes) >= modNotesMaxPerUser {
+		return "Too many notes for that user!, please clean up before adding more :3"
+	}
+
+	id, err := moddingDS.addModNote(guildID, userID, authorID, truncateString(content, modNoteMaxLength))
+	if err != nil {
+		return "There was an error storing the note: " + err.Error()
+	}
+	return fmt.Sprintf("Note #%d added to <@%s>", id, userID)
+}
+
+func (n ModNote) ShortString() string {
+	return fmt.Sprintf("`#%d` By <@%s> at <t:%d>: '%s'", n.ID, n.AuthorID, n.CreatedAt.Unix(), n.Content)
+}
+
+func modNotesString(notes []ModNote) string {
+	var b strings.Builder
+	for _, n := range notes {
+		b.WriteString(n.ShortString() + "\n")
+	}
+	return b.String()
+}
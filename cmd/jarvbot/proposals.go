@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	buttonReducerMap["cmdproposalapprove"] = handleCommandProposalApproveBtn
+	buttonReducerMap["cmdproposalreject"] = handleCommandProposalRejectBtn
+}
+
+// Command Answers
+
+func answerProposeCommand(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	reviewChannelID, err := serverDS.getServerProperty(mc.GuildID, serverPropCommandProposals)
+	if err != nil || reviewChannelID == "" {
+		ds.ChannelMessageSend(mc.ChannelID, "This server is not accepting command proposals, ask a mod to use !commandproposalshere first")
+		return false
+	}
+
+	commandBody := commandPrefixRegex.ReplaceAllString(mc.Content, "")
+	key := strings.TrimSpace(commandPrefixRegex.FindString(commandBody))
+	response := commandPrefixRegex.ReplaceAllString(commandBody, "")
+
+	if err := validateCommandProposal(key, response, mc.GuildID, mc.Author.ID); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not propose the command: "+err.Error())
+		return false
+	}
+
+	proposalID, err := commandDS.addCommandProposal(mc.GuildID, key, response, mc.Author.ID)
+	if err != nil {
+		serverNotifyIfErr("answerProposeCommand::addCommandProposal", err, mc.GuildID, ds)
+		ds.ChannelMessageSend(mc.ChannelID, "Could not store the proposal u_u")
+		return false
+	}
+
+	proposal := CommandProposal{ID: proposalID, GuildID: mc.GuildID, Key: key, Response: response, ProposedBy: mc.Author.ID}
+	idStr := strconv.Itoa(proposalID)
+	reviewMsg, err := ds.ChannelMessageSendComplex(reviewChannelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{commandProposalEmbed(proposal, colorBlue, "")},
+		Components: *buildButtonComponents([]*discordgo.Button{
+			newButton("Approve", discordgo.SuccessButton, "cmdproposalapprove"+buttonCustomIdSeparator+idStr),
+			newButton("Reject", discordgo.DangerButton, "cmdproposalreject"+buttonCustomIdSeparator+idStr),
+		}),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		serverNotifyIfErr("answerProposeCommand::ChannelMessageSendComplex", err, mc.GuildID, ds)
+		commandDS.reviewCommandProposal(proposalID, proposalStatusRejected, ds.State.User.ID)
+		ds.ChannelMessageSend(mc.ChannelID, "Could not send the proposal to the mods u_u")
+		return false
+	}
+	commandDS.setCommandProposalReviewMessage(proposalID, reviewMsg.ChannelID, reviewMsg.ID)
+
+	ds.ChannelMessageSend(mc.ChannelID, "Proposal sent! The mods will review it soon :3")
+	return true
+}
+
+func answerCommandProposalsHere(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	err := serverDS.setServerProperty(mc.GuildID, serverPropCommandProposals, mc.ChannelID)
+	serverNotifyIfErr("answerCommandProposalsHere", err, mc.GuildID, ds)
+	if err == nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Okay! Will send command proposals to this channel")
+	}
+	return err == nil
+}
+
+// Button handlers
+
+func handleCommandProposalApproveBtn(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	return reviewCommandProposal(ds, ic, data, proposalStatusApproved)
+}
+
+func handleCommandProposalRejectBtn(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	return reviewCommandProposal(ds, ic, data, proposalStatusRejected)
+}
+
+func reviewCommandProposal(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string, status string) error {
+	reviewer := interactionUser(ic)
+	if !isMod(ds, reviewer.ID, ic.ChannelID) {
+		return ephemeralRespond(ds, ic, userMustBeModMessage)
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("unexpected command proposal button data: %v", data)
+	}
+	proposalID, err := strconv.Atoi(data[1])
+	if err != nil {
+		return err
+	}
+
+	proposal, err := commandDS.commandProposal(proposalID)
+	if err != nil {
+		ephemeralRespond(ds, ic, "Could not find that proposal u_u")
+		return err
+	}
+
+	err = commandDS.reviewCommandProposal(proposalID, status, reviewer.ID)
+	if err == errZeroRowsAffected {
+		return ephemeralRespond(ds, ic, "That proposal was already reviewed")
+	}
+	if err != nil {
+		ephemeralRespond(ds, ic, "Could not review the proposal: "+err.Error())
+		return err
+	}
+
+	color := colorRed
+	if status == proposalStatusApproved {
+		color = colorGreen
+		err = validateAndAddCommand(proposal.Key, proposal.Response, proposal.GuildID, proposal.ProposedBy)
+		if err != nil {
+			commandDS.resetCommandProposal(proposalID)
+			return ephemeralRespond(ds, ic, "Could not create the command: "+err.Error())
+		}
+	}
+
+	footer := fmt.Sprintf("%s by %s", strings.ToLower(status), reviewer.Username)
+	err = ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{commandProposalEmbed(proposal, color, footer)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	serverNotifyIfErr("reviewCommandProposal::InteractionRespond", err, ic.GuildID, ds)
+
+	notifyCommandProposalAuthor(ds, proposal, status)
+	return nil
+}
+
+// Internal functions
+
+func validateCommandProposal(key, response, guildID, userID string) error {
+	if key == "" {
+		return errors.New("Command keys can't be empty")
+	}
+	if len(key) > commandKeyMaxLength {
+		return errors.New("That command key is too long! :<")
+	}
+	if strings.TrimSpace(response) == "" {
+		return errors.New("Command responses can't be empty u_u")
+	}
+
+	exists, err := commandDS.simpleCommandExists(key, guildID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errDuplicateCommand
+	}
+
+	pending, err := commandDS.countPendingCommandProposals(guildID, userID)
+	if err != nil {
+		return err
+	}
+	if pending >= commandProposalMaxPendingPerUser && !isAdmin(userID) {
+		return fmt.Errorf("You already have %d proposals waiting for review, please be patient :3", pending)
+	}
+	return nil
+}
+
+func commandProposalEmbed(proposal CommandProposal, color int, footer string) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Command proposal #%d", proposal.ID),
+		Color: color,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Command", Value: proposal.Key, Inline: true},
+			{Name: "Proposed by", Value: fmt.Sprintf("<@%s>", proposal.ProposedBy), Inline: true},
+			{Name: "Response", Value: truncateString(proposal.Response, 1000)},
+		},
+	}
+	if footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: footer}
+	}
+	return embed
+}
+
+func notifyCommandProposalAuthor(ds *discordgo.Session, proposal CommandProposal, status string) {
+	guildName := proposal.GuildID
+	if g, err := ds.State.Guild(proposal.GuildID); err == nil {
+		guildName = g.Name
+	}
+
+	var msg string
+	if status == proposalStatusApproved {
+		msg = fmt.Sprintf("Your command proposal %s in %s has been approved! Go try it :3", proposal.Key, guildName)
+	} else {
+		msg = fmt.Sprintf("Your command proposal %s in %s has been rejected u_u", proposal.Key, guildName)
+	}
+	sendDirectMessage(proposal.ProposedBy, msg, ds)
+}
@@ -6,6 +6,9 @@ import (
 	"log"
 	"regexp"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -20,6 +23,8 @@ type React4RoleMessage struct {
 	EmojiName      string    `db:"EmojiName"`
 	RoleID         string    `db:"RoleID"`
 	RequiredRoleID string    `db:"RequiredRoleID"`
+	GroupMode      string    `db:"GroupMode"`
+	GroupLimit     int       `db:"GroupLimit"`
 	CreatedAt      time.Time `db:"CreatedAt"`
 }
 
@@ -50,8 +55,36 @@ func (r React4RoleMessage) IsMyEmoji(e discordgo.Emoji) bool {
 func (r React4RoleMessage) FormattedEmojiString() string {
 	if r.EmojiID != "" {
 		return fmt.Sprintf("<:%s:%s>", r.EmojiName, r.EmojiID)
-	} else {
+	} else if react4RoleEmojiAliasRegex.MatchString(r.EmojiName) {
 		return ":" + r.EmojiName + ":"
+	} else {
+		// already parsed, like the stored ones
+		return r.EmojiName
+	}
+}
+
+func (r React4RoleMessage) HasSameEmoji(other React4RoleMessage) bool {
+	return r.IsMyEmoji(discordgo.Emoji{ID: other.EmojiID, Name: other.EmojiName})
+}
+
+// MatchesToken checks if the emote, emoji or role ID of a !react4rolesremove argument is the one of r
+func (r React4RoleMessage) MatchesToken(token string) bool {
+	if m := roleMenuEmoteRegex.FindStringSubmatch(token); m != nil {
+		return r.EmojiID == m[2]
+	}
+	if ids := extractDiscordIDs(token); len(ids) == 1 {
+		return r.RoleID == ids[0]
+	}
+	name := strings.Trim(token, ":")
+	return r.EmojiID == "" && (r.EmojiName == name || r.EmojiName == emoji.Parse(":"+name+":"))
+}
+
+// APIEmoji is the emoji in the format the reaction endpoints expect
+func (r React4RoleMessage) APIEmoji() string {
+	if r.EmojiID != "" {
+		return r.EmojiName + ":" + r.EmojiID
+	} else {
+		return r.EmojiName
 	}
 }
 
@@ -75,19 +108,32 @@ func onMessageReacted(ctx context.Context) func(ds *discordgo.Session, mc *disco
 		}
 
 		for _, r4r := range r4rs {
-			if r4r.IsMyEmoji(mc.Emoji) {
-
-				if r4r.RequiredRoleID != "" && !isMemberInRole(mc.Member, r4r.RequiredRoleID) {
-					sendDirectMessage(mc.UserID, "You can't have that role! :<", ds)
-					return
-				}
+			if !r4r.IsMyEmoji(mc.Emoji) {
+				continue
+			}
 
-				action := fmt.Sprintf("Added role %s to user %s in %s", r4r.RoleID, mc.UserID, mc.GuildID)
-				err := ds.GuildMemberRoleAdd(mc.GuildID, mc.UserID, r4r.RoleID)
+			// in drop mode, reacting is how the role is removed
+			if !roleGroupCanAdd(r4r.GroupMode) {
+				action := fmt.Sprintf("Removed role %s from user %s in %s", r4r.RoleID, mc.UserID, mc.GuildID)
+				err := ds.GuildMemberRoleRemove(mc.GuildID, mc.UserID, r4r.RoleID)
 				serverNotifyIfErr(action, err, mc.GuildID, ds)
-				if err != nil {
-					log.Println(action)
-				}
+				continue
+			}
+
+			if r4r.RequiredRoleID != "" && !isMemberInRole(mc.Member, r4r.RequiredRoleID) {
+				sendDirectMessage(mc.UserID, "You can't have that role! :<", ds)
+				return
+			}
+
+			if !enforceReact4RolesGroup(ds, mc, r4r, r4rs) {
+				return
+			}
+
+			action := fmt.Sprintf("Added role %s to user %s in %s", r4r.RoleID, mc.UserID, mc.GuildID)
+			err := ds.GuildMemberRoleAdd(mc.GuildID, mc.UserID, r4r.RoleID)
+			serverNotifyIfErr(action, err, mc.GuildID, ds)
+			if err != nil {
+				log.Println(action)
 			}
 		}
 	}
@@ -112,7 +158,8 @@ func onMessageUnreacted(ctx context.Context) func(ds *discordgo.Session, mc *dis
 			return
 		}
 		for _, r4r := range r4rs {
-			if r4r.IsMyEmoji(mc.Emoji) {
+			// in verify and drop modes, unreacting does nothing
+			if r4r.IsMyEmoji(mc.Emoji) && roleGroupCanAdd(r4r.GroupMode) && roleGroupCanRemove(r4r.GroupMode) {
 				action := fmt.Sprintf("Removed role %s from user %s in %s", r4r.RoleID, mc.UserID, mc.GuildID)
 				err := ds.GuildMemberRoleRemove(mc.GuildID, mc.UserID, r4r.RoleID)
 				serverNotifyIfErr(action, err, mc.GuildID, ds)
@@ -134,6 +181,16 @@ func answerMakeReact4RolesMsg(ds *discordgo.Session, mc *discordgo.MessageCreate
 		return false
 	}
 
+	groupMode, groupLimit, err := extractReact4RolesGroupMode(mc.Content)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+	for i := range r4rs {
+		r4rs[i].GroupMode = groupMode
+		r4rs[i].GroupLimit = groupLimit
+	}
+
 	roles, err := ds.GuildRoles(mc.GuildID)
 	if err != nil {
 		ds.ChannelMessageSend(mc.ChannelID, "I don't have role management perms! >:(")
@@ -144,16 +201,11 @@ func answerMakeReact4RolesMsg(ds *discordgo.Session, mc *discordgo.MessageCreate
 	if err != nil {
 		return false
 	}
+	parseReact4RolesEmojis(r4rs)
 	for i, r4r := range r4rs {
 		r4rs[i].ChannelID = response.ChannelID
 		r4rs[i].MessageID = response.ID
-		if r4r.EmojiID == "" {
-			parsed := emoji.Parse(r4r.FormattedEmojiString())
-			r4rs[i].EmojiName = parsed
-			err = ds.MessageReactionAdd(response.ChannelID, response.ID, parsed)
-		} else {
-			err = ds.MessageReactionAdd(response.ChannelID, response.ID, r4r.EmojiName+":"+r4r.EmojiID)
-		}
+		err = ds.MessageReactionAdd(response.ChannelID, response.ID, r4r.APIEmoji())
 		serverNotifyIfErr("answerMakeReact4RolesMsg::MessageReactionAdd", err, mc.GuildID, ds)
 	}
 
@@ -167,6 +219,73 @@ func answerMakeReact4RolesMsg(ds *discordgo.Session, mc *discordgo.MessageCreate
 	return err == nil
 }
 
+// Format: !react4rolesadd messageID (emoji roleID [requiredRoleID])... [mode:...]
+// A pair with an emoji that is already in the message replaces the role of that emoji
+func answerAddReact4Roles(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	current, ok := react4RolesToEdit(ds, mc)
+	if !ok {
+		return false
+	}
+	changes := extractReact4Roles(mc.Content)
+	if len(changes) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Sowwy, I couldn't find any React4Role rules u_u")
+		return false
+	}
+	parseReact4RolesEmojis(changes)
+
+	updated := slices.Clone(current)
+	for _, c := range changes {
+		i := slices.IndexFunc(updated, c.HasSameEmoji)
+		if i >= 0 {
+			updated[i] = c
+		} else {
+			updated = append(updated, c)
+		}
+	}
+	return editReact4Roles(ds, mc, current, updated)
+}
+
+// Format: !react4rolesremove messageID emoji|roleID... [mode:...]
+func answerRemoveReact4Roles(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	current, ok := react4RolesToEdit(ds, mc)
+	if !ok {
+		return false
+	}
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))[1:]
+
+	updated := []React4RoleMessage{}
+	for _, r4r := range current {
+		if !slices.ContainsFunc(args, r4r.MatchesToken) {
+			updated = append(updated, r4r)
+		}
+	}
+	if len(updated) == len(current) {
+		ds.ChannelMessageSend(mc.ChannelID, "Sowwy, none of those are in that message u_u")
+		return false
+	}
+	if len(updated) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "That would remove every role, just delete the message instead :3")
+		return false
+	}
+	return editReact4Roles(ds, mc, current, updated)
+}
+
+// Format: !react4rolesreplace messageID (emoji roleID [requiredRoleID])... [mode:...]
+// All the pairs of the message are replaced by the new ones
+func answerReplaceReact4Roles(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	current, ok := react4RolesToEdit(ds, mc)
+	if !ok {
+		return false
+	}
+	updated := extractReact4Roles(mc.Content)
+	if len(updated) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Sowwy, I couldn't find any React4Role rules u_u")
+		return false
+	}
+	parseReact4RolesEmojis(updated)
+	return editReact4Roles(ds, mc, current, updated)
+}
+
 // CRONs
 
 func react4RolesCRONFunc(ds *discordgo.Session) func() {
@@ -191,6 +310,9 @@ func react4RolesCRONFunc(ds *discordgo.Session) func() {
 
 const react4RoleEmoteRgx = `\(\s*<:([^:]+):(\d+)>\s+(\d+)\s*(\d+)?\s*\)`
 const react4RoleEmojiRgx = `\(\s*([a-z0-9_]+)\s+(\d+)\s*(\d+)?\s*\)`
+const react4RoleModeRgx = `(?i)\bmode:(\w+(?::\d+)?)`
+
+var react4RoleEmojiAliasRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
 
 func extractReact4Roles(message string) []React4RoleMessage {
 	r4rs := []React4RoleMessage{}
@@ -225,10 +347,207 @@ func extractReact4Roles(message string) []React4RoleMessage {
 	return r4rs
 }
 
+// parseReact4RolesEmojis turns emoji names like red_square into the emoji itself, which is how they are stored
+func parseReact4RolesEmojis(r4rs []React4RoleMessage) {
+	for i, r4r := range r4rs {
+		if r4r.EmojiID == "" {
+			r4rs[i].EmojiName = emoji.Parse(r4r.FormattedEmojiString())
+		}
+	}
+}
+
+// react4RolesToEdit returns the pairs of the message whose ID (or link) is the first argument of the command
+func react4RolesToEdit(ds *discordgo.Session, mc *discordgo.MessageCreate) ([]React4RoleMessage, bool) {
+	args := strings.Fields(commandPrefixRegex.ReplaceAllString(mc.Content, ""))
+	var ids []string
+	if len(args) > 0 {
+		ids = extractDiscordIDs(args[0])
+	}
+	if len(ids) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Please tell me the ID of the react4roles message first :3")
+		return nil, false
+	}
+
+	r4rs, err := moddingDS.react4RolesByMessageID(ids[len(ids)-1])
+	if err != nil || len(r4rs) == 0 || !channelBelongsToGuild(ds, r4rs[0].ChannelID, mc.GuildID) {
+		ds.ChannelMessageSend(mc.ChannelID, "Sowwy, I couldn't find that react4roles message u_u")
+		return nil, false
+	}
+	return r4rs, true
+}
+
+// editReact4Roles stores the updated pairs, edits the message and updates the bot's reactions.
+// The group mode is kept unless the command has a new one
+func editReact4Roles(ds *discordgo.Session, mc *discordgo.MessageCreate, current, updated []React4RoleMessage) bool {
+	if len(updated) > react4RolesMaxPairs {
+		ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Too many roles!, a message can only have %d reactions", react4RolesMaxPairs))
+		return false
+	}
+
+	groupMode, groupLimit := current[0].GroupMode, current[0].GroupLimit
+	if regexp.MustCompile(react4RoleModeRgx).MatchString(mc.Content) {
+		var err error
+		groupMode, groupLimit, err = extractReact4RolesGroupMode(mc.Content)
+		if err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, err.Error())
+			return false
+		}
+	}
+
+	channelID, messageID := current[0].ChannelID, current[0].MessageID
+	for i := range updated {
+		updated[i].ChannelID = channelID
+		updated[i].MessageID = messageID
+		updated[i].GroupMode = groupMode
+		updated[i].GroupLimit = groupLimit
+	}
+
+	roles, err := ds.GuildRoles(mc.GuildID)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "I don't have role management perms! >:(")
+		return false
+	}
+	// the message is only edited once the new pairs are stored, so it never shows pairs that don't work
+	err = moddingDS.replaceReact4Roles(channelID, messageID, updated)
+	if err != nil {
+		serverNotifyIfErr("editReact4Roles::replaceReact4Roles", err, mc.GuildID, ds)
+		ds.ChannelMessageSend(mc.ChannelID, "Something went wrong, blame Jarv :3c")
+		return false
+	}
+
+	_, err = ds.ChannelMessageEdit(channelID, messageID, buildReact4RolesMessage(updated, roles))
+	if err != nil {
+		serverNotifyIfErr("editReact4Roles::replaceReact4Roles", moddingDS.replaceReact4Roles(channelID, messageID, current), mc.GuildID, ds)
+		ds.ChannelMessageSend(mc.ChannelID, "Could not edit that message: "+err.Error())
+		return false
+	}
+
+	// the reactions of removed pairs, or of emojis that now give another role, are cleared so they don't
+	// remove the new role when unreacted. The bot's own reaction is added back as a seed
+	unchanged := func(list []React4RoleMessage, r4r React4RoleMessage) bool {
+		return slices.ContainsFunc(list, func(other React4RoleMessage) bool {
+			return other.HasSameEmoji(r4r) && other.RoleID == r4r.RoleID
+		})
+	}
+	for _, r4r := range current {
+		if !unchanged(updated, r4r) {
+			err = ds.MessageReactionsRemoveEmoji(channelID, messageID, r4r.APIEmoji())
+			serverNotifyIfErr("editReact4Roles::MessageReactionsRemoveEmoji", err, mc.GuildID, ds)
+		}
+	}
+	for _, r4r := range updated {
+		if !unchanged(current, r4r) {
+			err = ds.MessageReactionAdd(channelID, messageID, r4r.APIEmoji())
+			serverNotifyIfErr("editReact4Roles::MessageReactionAdd", err, mc.GuildID, ds)
+		}
+	}
+
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+// extractReact4RolesGroupMode reads the optional mode:unique, mode:limit:N, mode:verify or mode:drop
+func extractReact4RolesGroupMode(message string) (string, int, error) {
+	m := regexp.MustCompile(react4RoleModeRgx).FindStringSubmatch(message)
+	if m == nil {
+		return "", 0, nil
+	}
+	return parseRoleGroupMode(m[1])
+}
+
+// enforceReact4RolesGroup removes the other roles of the message in unique mode, and checks the limit in limit mode.
+// Returns false if the user can't get the role of r4r
+func enforceReact4RolesGroup(ds *discordgo.Session, mc *discordgo.MessageReactionAdd, r4r React4RoleMessage, r4rs []React4RoleMessage) bool {
+	maxRoles := roleGroupMaxRoles(r4r.GroupMode, r4r.GroupLimit)
+	if maxRoles == 0 || isMemberInRole(mc.Member, r4r.RoleID) {
+		return true
+	}
+
+	var held []React4RoleMessage
+	for _, other := range r4rs {
+		if other.RoleID != r4r.RoleID && isMemberInRole(mc.Member, other.RoleID) {
+			held = append(held, other)
+		}
+	}
+
+	if r4r.GroupMode == roleGroupModeUnique {
+		for _, other := range held {
+			// the user may have the role without having reacted, so it's not left to onMessageUnreacted
+			err := ds.GuildMemberRoleRemove(mc.GuildID, mc.UserID, other.RoleID)
+			serverNotifyIfErr(fmt.Sprintf("Removed role %s from user %s in %s", other.RoleID, mc.UserID, mc.GuildID), err, mc.GuildID, ds)
+			ds.MessageReactionRemove(mc.ChannelID, mc.MessageID, other.APIEmoji(), mc.UserID)
+		}
+		return true
+	}
+
+	if len(held) >= maxRoles {
+		ds.MessageReactionRemove(mc.ChannelID, mc.MessageID, r4r.APIEmoji(), mc.UserID)
+		sendDirectMessage(mc.UserID, fmt.Sprintf("You can only have %d roles from that message! :<", maxRoles), ds)
+		return false
+	}
+	return true
+}
+
 func buildReact4RolesMessage(r4rs []React4RoleMessage, roles []*discordgo.Role) string {
 	msg := "React to this message to get or remove roles:\n"
+	if len(r4rs) > 0 && r4rs[0].GroupMode != "" {
+		msg = fmt.Sprintf("React to this message to get or remove roles (%s):\n", roleGroupDescription(r4rs[0].GroupMode, r4rs[0].GroupLimit))
+	}
 	for _, r4r := range r4rs {
 		msg += "> " + r4r.String(roles) + "\n"
 	}
 	return msg
 }
+
+// Role groups, shared by react4roles messages and role menus
+
+// parseRoleGroupMode parses unique, verify, drop or limit:N
+func parseRoleGroupMode(s string) (string, int, error) {
+	mode, limitStr, _ := strings.Cut(strings.ToLower(s), ":")
+	switch mode {
+	case "none":
+		return "", 0, nil
+	case roleGroupModeUnique, roleGroupModeVerify, roleGroupModeDrop:
+		return mode, 0, nil
+	case roleGroupModeLimit:
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > roleMenuMaxRoles {
+			return "", 0, fmt.Errorf("the limit mode needs a number between 1 and %d, like mode:limit:2", roleMenuMaxRoles)
+		}
+		return mode, limit, nil
+	}
+	return "", 0, fmt.Errorf("unknown mode '%s', the modes are unique, limit:N, verify, drop and none", s)
+}
+
+// roleGroupMaxRoles returns how many roles of the group a member can have, 0 means there is no limit
+func roleGroupMaxRoles(mode string, limit int) int {
+	switch mode {
+	case roleGroupModeUnique:
+		return 1
+	case roleGroupModeLimit:
+		return limit
+	}
+	return 0
+}
+
+func roleGroupCanAdd(mode string) bool {
+	return mode != roleGroupModeDrop
+}
+
+func roleGroupCanRemove(mode string) bool {
+	return mode != roleGroupModeVerify
+}
+
+func roleGroupDescription(mode string, limit int) string {
+	switch mode {
+	case roleGroupModeUnique:
+		return "only one role at a time"
+	case roleGroupModeLimit:
+		return fmt.Sprintf("up to %d roles", limit)
+	case roleGroupModeVerify:
+		return "the roles can't be removed"
+	case roleGroupModeDrop:
+		return "removes the roles"
+	}
+	return ""
+}
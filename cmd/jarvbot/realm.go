@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Command Answers
+
+// Format: !realmstatus [@user]
+func answerRealmStatus(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	userID := mc.Author.ID
+	if match := commandWithMention.FindStringSubmatch(mc.Content); match != nil {
+		userID = match[1]
+	}
+
+	releaseAt, err := shadowRealmReleaseTime(ds, mc.GuildID, userID)
+	if err != nil {
+		serverNotifyIfErr("answerRealmStatus", err, mc.GuildID, ds)
+		return false
+	}
+
+	var response string
+	if releaseAt.IsZero() {
+		response = fmt.Sprintf("<@%s> is not in the Shadow Realm :D", userID)
+	} else {
+		response = fmt.Sprintf("<@%s> will leave the Shadow Realm <t:%d:R>", userID, releaseAt.Unix())
+	}
+	_, err = ds.ChannelMessageSendComplex(mc.ChannelID, &discordgo.MessageSend{
+		Content:         response,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err == nil
+}
+
+// Format: !release @user
+func answerRelease(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	match := commandWithMention.FindStringSubmatch(mc.Content)
+	if match == nil || len(match) != 2 {
+		ds.ChannelMessageSend(mc.ChannelID, commandWithMentionError)
+		return false
+	}
+	userID := match[1]
+
+	released, err := releaseFromShadowRealm(ds, mc.GuildID, userID)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not release that user, maybe I'm missing permissions u_u")
+		serverNotifyIfErr("answerRelease", err, mc.GuildID, ds)
+		return false
+	}
+	if !released {
+		ds.ChannelMessageSend(mc.ChannelID, "That user is not in the Shadow Realm")
+		return false
+	}
+
+	recordModCase(ds, ModCase{
+		GuildID:  mc.GuildID,
+		ActorID:  mc.Author.ID,
+		TargetID: userID,
+		Action:   caseActionShadowRealmRelease,
+		Source:   caseSourceCommand,
+	})
+	ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("<@%s> has been released from the Shadow Realm", userID))
+	return true
+}
+
+func answerRealmed(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	releases, err := guildShadowRealmReleases(ds, mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerRealmed", err, mc.GuildID, ds)
+		return false
+	}
+	if len(releases) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Nobody is in the Shadow Realm right now :3")
+		return true
+	}
+
+	userIDs := make([]string, 0, len(releases))
+	for userID := range releases {
+		userIDs = append(userIDs, userID)
+	}
+	slices.SortFunc(userIDs, func(a, b string) int {
+		return releases[a].Compare(releases[b])
+	})
+
+	var b strings.Builder
+	for _, userID := range userIDs {
+		b.WriteString(fmt.Sprintf("<@%s> leaves <t:%d:R>\n", userID, releases[userID].Unix()))
+	}
+	_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%d users in the Shadow Realm", len(userIDs)),
+		Color:       colorBlack,
+		Description: truncateString(b.String(), embedDescriptionMaxLength),
+	})
+	return err == nil
+}
+
+// Internal functions
+
+// userShadowRealmActions returns the pending REMOVE_ROLE actions of the user in the guild
+func userShadowRealmActions(guildID, userID string) ([]ScheduledAction, error) {
+	actions, err := schedulerDS.getScheduledActionsByTargetIDAndActionType(userID, actionTypeRemoveRole)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(actions, func(a ScheduledAction) bool {
+		return !strings.HasPrefix(a.ActionData, guildID+";")
+	}), nil
+}
+
+// shadowRealmReleaseTime returns when the user leaves the realm, or a zero time if the user is not realmed
+func shadowRealmReleaseTime(ds *discordgo.Session, guildID, userID string) (time.Time, error) {
+	var releaseAt time.Time
+	actions, err := userShadowRealmActions(guildID, userID)
+	if err != nil {
+		return releaseAt, err
+	}
+	for _, a := range actions {
+		if a.ScheduledFor.After(releaseAt) {
+			releaseAt = a.ScheduledFor
+		}
+	}
+
+	if member, err := ds.GuildMember(guildID, userID); err == nil {
+		if until := member.CommunicationDisabledUntil; until != nil && until.After(time.Now()) && until.After(releaseAt) {
+			releaseAt = *until
+		}
+	}
+	return releaseAt, nil
+}
+
+// guildShadowRealmReleases maps each realmed user to their release time
+// Native timeouts are only found for the members in the state cache
+func guildShadowRealmReleases(ds *discordgo.Session, guildID string) (map[string]time.Time, error) {
+	actions, err := schedulerDS.getGuildScheduledActionsByActionType(guildID, actionTypeRemoveRole)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make(map[string]time.Time)
+	for _, a := range actions {
+		if a.ScheduledFor.After(releases[a.TargetID]) {
+			releases[a.TargetID] = a.ScheduledFor
+		}
+	}
+
+	if g, err := ds.State.Guild(guildID); err == nil {
+		for _, m := range g.Members {
+			until := m.CommunicationDisabledUntil
+			if m.User != nil && until != nil && until.After(time.Now()) && until.After(releases[m.User.ID]) {
+				releases[m.User.ID] = *until
+			}
+		}
+	}
+	return releases, nil
+}
+
+// releaseFromShadowRealm removes the timeout roles, cancels their scheduled removals and clears the native timeout
+// Returns false if the user was not realmed. Users that left the server only get the scheduled removals cancelled
+func releaseFromShadowRealm(ds *discordgo.Session, guildID, userID string) (bool, error) {
+	released := false
+	actions, err := userShadowRealmActions(guildID, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, a := range actions {
+		split := strings.Split(a.ActionData, ";")
+		if len(split) != 2 {
+			continue
+		}
+		// users that left still get the scheduled removal deleted, so the sticky roles don't realm them again
+		if err := ds.GuildMemberRoleRemove(guildID, userID, split[1]); err != nil && !isUnknownMemberErr(err) {
+			return released, err
+		}
+		schedulerDS.removeScheduledAction(a.ID)
+		released = true
+	}
+
+	member, err := ds.GuildMember(guildID, userID)
+	if isUnknownMemberErr(err) {
+		return released, nil
+	}
+	if err != nil {
+		return released, err
+	}
+	if timeoutRoleID, err := resolveTimeoutRoleID(ds, guildID); err == nil && timeoutRoleID != "" && isMemberInRole(member, timeoutRoleID) {
+		if err := ds.GuildMemberRoleRemove(guildID, userID, timeoutRoleID); err != nil {
+			return released, err
+		}
+		released = true
+	}
+	if until := member.CommunicationDisabledUntil; until != nil && until.After(time.Now()) {
+		if err := ds.GuildMemberTimeout(guildID, userID, nil); err != nil {
+			return released, err
+		}
+		released = true
+	}
+	return released, nil
+}
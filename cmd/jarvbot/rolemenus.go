@@ -0,0 +1,587 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	buttonReducerMap["rolemenumodal"] = handleRoleMenuModal
+	buttonReducerMap["rolemenu"] = handleRoleMenuBtn
+	buttonReducerMap["rolemenuselect"] = handleRoleMenuSelect
+	buttonReducerMap["rolemenumine"] = handleMemberRoleMenuSelect
+}
+
+var roleMenuEmoteRegex = regexp.MustCompile(`<a?:(\w+):(\d+)>`)
+
+// Slash Command answers
+
+func answerRoleMenu(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if !isMod(ds, interactionUser(ic).ID, ic.ChannelID) {
+		ephemeralRespond(ds, ic, userMustBeModMessage)
+		return
+	}
+
+	subcommand := ic.ApplicationCommandData().Options[0]
+	options := optionMap(subcommand.Options)
+
+	switch subcommand.Name {
+	case "create":
+		groupMode := ""
+		if opt, ok := options["mode"]; ok {
+			groupMode = opt.StringValue()
+		}
+		groupLimit := optionIntValueOrZero(options["limit"])
+		if groupMode == roleGroupModeLimit && groupLimit == 0 {
+			ephemeralRespond(ds, ic, "The limit mode needs a limit! :<")
+			return
+		}
+
+		customID := strings.Join([]string{"rolemenumodal", options["style"].StringValue(), groupMode, strconv.Itoa(groupLimit)}, buttonCustomIdSeparator)
+		err := ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: customID,
+				Title:    "New role menu",
+				Components: []discordgo.MessageComponent{
+					modalTextInput("title", "Message", discordgo.TextInputParagraph, roleMenuDefaultTitle, roleMenuTitleMaxLength),
+					modalTextInput("roles", "One role per line: [emote] @role [@required]", discordgo.TextInputParagraph, "", modalTextInputMaxLength),
+				},
+			},
+		})
+		serverNotifyIfErr("answerRoleMenu::InteractionRespond", err, ic.GuildID, ds)
+	case "migrate":
+		style := roleMenuStyleButtons
+		if opt, ok := options["style"]; ok {
+			style = opt.StringValue()
+		}
+		messageID := ""
+		if opt, ok := options["message_id"]; ok {
+			messageID = strings.TrimSpace(opt.StringValue())
+		}
+
+		// editing every message can take longer than the 3 seconds Discord gives to answer
+		err := ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+		})
+		if err != nil {
+			serverNotifyIfErr("answerRoleMenu::InteractionRespond", err, ic.GuildID, ds)
+			return
+		}
+
+		result := migrateReact4Roles(ds, ic.GuildID, messageID, style)
+		_, err = ds.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{Content: &result})
+		serverNotifyIfErr("answerRoleMenu::InteractionResponseEdit", err, ic.GuildID, ds)
+	}
+}
+
+// Modal and component handlers
+
+func handleRoleMenuModal(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if len(data) < 4 {
+		return fmt.Errorf("unexpected role menu modal data: %v", data)
+	}
+	if !isMod(ds, interactionUser(ic).ID, ic.ChannelID) {
+		return ephemeralRespond(ds, ic, userMustBeModMessage)
+	}
+
+	values := modalTextValues(ic)
+	entries, err := parseRoleMenuEntries(values["roles"])
+	if err != nil {
+		return ephemeralRespond(ds, ic, "Could not create the role menu: "+err.Error())
+	}
+	roles, err := ds.GuildRoles(ic.GuildID)
+	if err != nil {
+		return ephemeralRespond(ds, ic, "I don't have role management perms! >:(")
+	}
+	for _, e := range entries {
+		for _, roleID := range []string{e.RoleID, e.RequiredRoleID} {
+			if roleID != "" && (roleID == ic.GuildID || findRoleInSlice(roleID, roles) == nil) {
+				return ephemeralRespond(ds, ic, fmt.Sprintf("Could not find the role %s in this server", roleID))
+			}
+		}
+	}
+
+	groupLimit, _ := strconv.Atoi(data[3])
+	for i := range entries {
+		entries[i].Style = data[1]
+		entries[i].GroupMode = data[2]
+		entries[i].GroupLimit = groupLimit
+	}
+
+	title := values["title"]
+	if title == "" {
+		title = roleMenuDefaultTitle
+	}
+	msg, err := ds.ChannelMessageSendComplex(ic.ChannelID, &discordgo.MessageSend{
+		Content:         buildRoleMenuMessage(title, entries),
+		Components:      roleMenuComponents(data[1], entries, roles),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		return ephemeralRespond(ds, ic, "Could not send the role menu: "+err.Error())
+	}
+
+	for i := range entries {
+		entries[i].GuildID = ic.GuildID
+		entries[i].ChannelID = msg.ChannelID
+		entries[i].MessageID = msg.ID
+	}
+	if err = moddingDS.addRoleMenuEntries(entries); err != nil {
+		ds.ChannelMessageDelete(msg.ChannelID, msg.ID)
+		moddingDS.deleteRoleMenu(msg.ID)
+		return ephemeralRespond(ds, ic, "Could not save the role menu: "+err.Error())
+	}
+	return ephemeralRespond(ds, ic, commandSuccessMessage)
+}
+
+// handleRoleMenuBtn toggles the role of the button
+func handleRoleMenuBtn(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if len(data) < 2 || ic.Member == nil {
+		return fmt.Errorf("unexpected role menu button data: %v", data)
+	}
+	entries, err := moddingDS.roleMenuEntries(ic.Message.ID)
+	i := slices.IndexFunc(entries, func(e RoleMenuEntry) bool { return e.RoleID == data[1] })
+	if err != nil || i < 0 {
+		return ephemeralRespond(ds, ic, "This role menu is not available anymore u_u")
+	}
+
+	var result roleMenuResult
+	if denial := result.toggle(ds, ic.GuildID, ic.Member, entries, entries[i], memberRoleMenuRoles(ic.Member, entries)); denial != "" {
+		return ephemeralRespond(ds, ic, denial)
+	}
+	return ephemeralRespond(ds, ic, result.String())
+}
+
+// handleRoleMenuSelect toggles the picked roles, like the buttons do.
+// The select menu is shared, so it can't show the roles of each member. The answer includes a menu only for the member,
+// with the roles they have already selected
+func handleRoleMenuSelect(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if ic.Member == nil {
+		return fmt.Errorf("role menu select used outside of a server")
+	}
+	entries, err := moddingDS.roleMenuEntries(ic.Message.ID)
+	if err != nil || len(entries) == 0 {
+		return ephemeralRespond(ds, ic, "This role menu is not available anymore u_u")
+	}
+
+	var result roleMenuResult
+	var denials []string
+	held := memberRoleMenuRoles(ic.Member, entries)
+	for _, roleID := range ic.MessageComponentData().Values {
+		i := slices.IndexFunc(entries, func(e RoleMenuEntry) bool { return e.RoleID == roleID })
+		if i < 0 {
+			continue
+		}
+		if denial := result.toggle(ds, ic.GuildID, ic.Member, entries, entries[i], held); denial != "" {
+			denials = append(denials, denial)
+		}
+	}
+	return respondWithMemberRoleMenu(ds, ic, discordgo.InteractionResponseChannelMessageWithSource, ic.Message.ID, entries, held, result, denials)
+}
+
+// handleMemberRoleMenuSelect handles the menu that only the member sees, it has the member's roles preselected,
+// so the unselected roles can be removed
+func handleMemberRoleMenuSelect(ds *discordgo.Session, ic *discordgo.InteractionCreate, data []string) error {
+	if len(data) < 2 || ic.Member == nil {
+		return fmt.Errorf("unexpected member role menu data: %v", data)
+	}
+	entries, err := moddingDS.roleMenuEntries(data[1])
+	if err != nil || len(entries) == 0 {
+		return ephemeralRespond(ds, ic, "This role menu is not available anymore u_u")
+	}
+
+	selected := map[string]bool{}
+	for _, roleID := range ic.MessageComponentData().Values {
+		selected[roleID] = true
+	}
+	maxRoles := roleGroupMaxRoles(entries[0].GroupMode, entries[0].GroupLimit)
+	if maxRoles > 0 && len(selected) > maxRoles {
+		return ephemeralRespond(ds, ic, fmt.Sprintf("You can only have %d roles from this menu! :<", maxRoles))
+	}
+
+	// unique and limit are enforced by the max values of the select menu, verify and drop are enforced here
+	var result roleMenuResult
+	held := memberRoleMenuRoles(ic.Member, entries)
+	for _, e := range entries {
+		add := selected[e.RoleID]
+		if add == held[e.RoleID] {
+			continue
+		}
+		if (add && roleGroupCanAdd(e.GroupMode)) || (!add && roleGroupCanRemove(e.GroupMode)) {
+			if result.apply(ds, ic.GuildID, ic.Member, e, add) {
+				held[e.RoleID] = add
+			}
+		}
+	}
+	return respondWithMemberRoleMenu(ds, ic, discordgo.InteractionResponseUpdateMessage, data[1], entries, held, result, nil)
+}
+
+// CRONs
+
+func roleMenusCRONFunc(ds *discordgo.Session) func() {
+	return func() {
+		// Checks which role menus can be removed from DB
+		menus, err := moddingDS.allRoleMenus()
+		if err != nil {
+			adminNotifyIfErr("roleMenusCRONFunc", err, ds)
+			return
+		}
+		for _, m := range menus {
+			_, err = ds.ChannelMessage(m.ChannelID, m.MessageID)
+			restErr, ok := (err).(*discordgo.RESTError)
+			if ok && restErr.Response.StatusCode == 404 {
+				moddingDS.deleteRoleMenu(m.MessageID)
+			}
+		}
+	}
+}
+
+// Internal functions
+
+type roleMenuResult struct {
+	added   []string
+	removed []string
+	denied  []string
+	failed  []string
+}
+
+// toggle adds or removes the role of the entry, following the group mode of the menu.
+// held has the menu roles of the member and is updated with the changes. Returns why the toggle is not allowed, if it isn't
+func (r *roleMenuResult) toggle(ds *discordgo.Session, guildID string, member *discordgo.Member, entries []RoleMenuEntry, entry RoleMenuEntry, held map[string]bool) string {
+	add := !held[entry.RoleID]
+	if add && !roleGroupCanAdd(entry.GroupMode) {
+		return "This menu can only remove roles :<"
+	}
+	if !add && !roleGroupCanRemove(entry.GroupMode) {
+		return "The roles of this menu can't be removed :<"
+	}
+
+	if maxRoles := roleGroupMaxRoles(entry.GroupMode, entry.GroupLimit); add && maxRoles > 0 {
+		if entry.GroupMode == roleGroupModeUnique {
+			for _, e := range entries {
+				if held[e.RoleID] && r.apply(ds, guildID, member, e, false) {
+					held[e.RoleID] = false
+				}
+			}
+		} else if countTrue(held) >= maxRoles {
+			return fmt.Sprintf("You can only have %d roles from this menu! :<", maxRoles)
+		}
+	}
+	if r.apply(ds, guildID, member, entry, add) {
+		held[entry.RoleID] = add
+	}
+	return ""
+}
+
+// apply adds or removes the role of the entry, and records what happened. Returns true if the role was changed
+func (r *roleMenuResult) apply(ds *discordgo.Session, guildID string, member *discordgo.Member, entry RoleMenuEntry, add bool) bool {
+	var err error
+	switch {
+	case add && entry.RequiredRoleID != "" && !isMemberInRole(member, entry.RequiredRoleID):
+		r.denied = append(r.denied, fmt.Sprintf("<@&%s> (requires <@&%s>)", entry.RoleID, entry.RequiredRoleID))
+		return false
+	case add:
+		err = ds.GuildMemberRoleAdd(guildID, member.User.ID, entry.RoleID)
+	default:
+		err = ds.GuildMemberRoleRemove(guildID, member.User.ID, entry.RoleID)
+	}
+
+	if err != nil {
+		serverNotifyIfErr(fmt.Sprintf("Couldn't update role <@&%s> of user <@%s> from a role menu", entry.RoleID, member.User.ID), err, guildID, ds)
+		r.failed = append(r.failed, "<@&"+entry.RoleID+">")
+		return false
+	}
+	if add {
+		r.added = append(r.added, "<@&"+entry.RoleID+">")
+	} else {
+		r.removed = append(r.removed, "<@&"+entry.RoleID+">")
+	}
+	return true
+}
+
+// memberRoleMenuRoles returns which roles of the menu the member has
+func memberRoleMenuRoles(member *discordgo.Member, entries []RoleMenuEntry) map[string]bool {
+	held := map[string]bool{}
+	for _, e := range entries {
+		held[e.RoleID] = isMemberInRole(member, e.RoleID)
+	}
+	return held
+}
+
+func countTrue(m map[string]bool) int {
+	count := 0
+	for _, v := range m {
+		if v {
+			count++
+		}
+	}
+	return count
+}
+
+// respondWithMemberRoleMenu answers with the result and a select menu, only for the member, with their roles preselected
+func respondWithMemberRoleMenu(ds *discordgo.Session, ic *discordgo.InteractionCreate, responseType discordgo.InteractionResponseType,
+	menuMessageID string, entries []RoleMenuEntry, held map[string]bool, result roleMenuResult, denials []string) error {
+	content := strings.Join(append(denials, result.String()), "\n")
+	var components []discordgo.MessageComponent
+	if roles, err := ds.GuildRoles(ic.GuildID); err == nil {
+		content += "\nYour roles from this menu are selected below, change them there:"
+		components = []discordgo.MessageComponent{
+			roleMenuSelect("rolemenumine"+buttonCustomIdSeparator+menuMessageID, entries, roles, held),
+		}
+	}
+	return ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: &discordgo.InteractionResponseData{
+			Content:         content,
+			Components:      components,
+			Flags:           discordgo.MessageFlagsEphemeral,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+}
+
+func (r roleMenuResult) String() string {
+	var lines []string
+	if len(r.added) > 0 {
+		lines = append(lines, "Added: "+strings.Join(r.added, ", "))
+	}
+	if len(r.removed) > 0 {
+		lines = append(lines, "Removed: "+strings.Join(r.removed, ", "))
+	}
+	if len(r.denied) > 0 {
+		lines = append(lines, "You can't have: "+strings.Join(r.denied, ", ")+" :<")
+	}
+	if len(r.failed) > 0 {
+		lines = append(lines, "Something went wrong with: "+strings.Join(r.failed, ", ")+", the mods were notified")
+	}
+	if len(lines) == 0 {
+		return "Nothing changed :3"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseRoleMenuEntries reads one entry per line, in the format: [<:emote:id>] @role [@requiredRole]
+func parseRoleMenuEntries(text string) ([]RoleMenuEntry, error) {
+	entries := []RoleMenuEntry{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		entry := RoleMenuEntry{}
+		if m := roleMenuEmoteRegex.FindStringSubmatch(line); m != nil {
+			entry.EmojiName = m[1]
+			entry.EmojiID = m[2]
+			line = strings.Replace(line, m[0], "", 1)
+		}
+		roleIDs := extractDiscordIDs(line)
+		if len(roleIDs) == 0 || len(roleIDs) > 2 {
+			return nil, fmt.Errorf("could not understand the line '%s'", line)
+		}
+		entry.RoleID = roleIDs[0]
+		if len(roleIDs) == 2 {
+			entry.RequiredRoleID = roleIDs[1]
+		}
+		if seen[entry.RoleID] {
+			return nil, fmt.Errorf("the role %s is repeated", entry.RoleID)
+		}
+		seen[entry.RoleID] = true
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("there are no roles")
+	}
+	if len(entries) > roleMenuMaxRoles {
+		return nil, fmt.Errorf("too many roles!, the max is %d", roleMenuMaxRoles)
+	}
+	return entries, nil
+}
+
+func buildRoleMenuMessage(title string, entries []RoleMenuEntry) string {
+	msg := title
+	if len(entries) > 0 && entries[0].GroupMode != "" {
+		msg += "\n-# " + roleGroupDescription(entries[0].GroupMode, entries[0].GroupLimit)
+	}
+	for _, e := range entries {
+		if e.RequiredRoleID != "" {
+			msg += fmt.Sprintf("\n> <@&%s> requires <@&%s>", e.RoleID, e.RequiredRoleID)
+		}
+	}
+	return msg
+}
+
+func roleMenuComponents(style string, entries []RoleMenuEntry, roles []*discordgo.Role) []discordgo.MessageComponent {
+	if style == roleMenuStyleSelect {
+		return []discordgo.MessageComponent{roleMenuSelect("rolemenuselect", entries, roles, nil)}
+	}
+
+	buttons := make([]*discordgo.Button, len(entries))
+	for i, e := range entries {
+		buttons[i] = newButton(e.Label(roles), discordgo.SecondaryButton, "rolemenu"+buttonCustomIdSeparator+e.RoleID)
+		buttons[i].Emoji = e.ComponentEmoji()
+	}
+	return *buildButtonComponents(buttons)
+}
+
+// roleMenuSelect builds the select menu of the entries, the held roles are preselected
+func roleMenuSelect(customID string, entries []RoleMenuEntry, roles []*discordgo.Role, held map[string]bool) discordgo.ActionsRow {
+	options := make([]discordgo.SelectMenuOption, len(entries))
+	for i, e := range entries {
+		options[i] = discordgo.SelectMenuOption{
+			Label:   e.Label(roles),
+			Value:   e.RoleID,
+			Emoji:   e.ComponentEmoji(),
+			Default: held[e.RoleID],
+		}
+		if e.RequiredRoleID != "" {
+			options[i].Description = truncateString("Requires "+RoleMenuEntry{RoleID: e.RequiredRoleID}.Label(roles), 100)
+		}
+	}
+
+	maxValues := len(options)
+	if maxRoles := roleGroupMaxRoles(entries[0].GroupMode, entries[0].GroupLimit); maxRoles > 0 {
+		maxValues = min(maxRoles, maxValues)
+	}
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				MenuType:    discordgo.StringSelectMenu,
+				CustomID:    customID,
+				Placeholder: "Pick roles to get or remove them",
+				MinValues:   new(int),
+				MaxValues:   maxValues,
+				Options:     options,
+			},
+		},
+	}
+}
+
+func (e RoleMenuEntry) Label(roles []*discordgo.Role) string {
+	role := findRoleInSlice(e.RoleID, roles)
+	if role == nil {
+		return "Unknown role " + e.RoleID
+	}
+	return truncateString(role.Name, 80)
+}
+
+func (e RoleMenuEntry) ComponentEmoji() *discordgo.ComponentEmoji {
+	// react4roles stores the unparsed name when it's not a known emoji, discord rejects those
+	if e.EmojiName == "" || (e.EmojiID == "" && strings.HasPrefix(e.EmojiName, ":")) {
+		return nil
+	}
+	return &discordgo.ComponentEmoji{Name: e.EmojiName, ID: e.EmojiID}
+}
+
+// migrateReact4Roles turns the react4roles messages of the guild into role menus, the same message is kept.
+// An empty messageID migrates all of them. Returns the message to show to the mod
+func migrateReact4Roles(ds *discordgo.Session, guildID, messageID, style string) string {
+	var r4rs []React4RoleMessage
+	var err error
+	if messageID != "" {
+		r4rs, err = moddingDS.react4RolesByMessageID(messageID)
+	} else {
+		r4rs, err = moddingDS.allReact4Roles()
+	}
+	if err != nil {
+		return "Couldn't get the react4roles messages: " + err.Error()
+	}
+
+	var messageIDs []string
+	byMessage := map[string][]React4RoleMessage{}
+	for _, r4r := range r4rs {
+		if !channelBelongsToGuild(ds, r4r.ChannelID, guildID) {
+			continue
+		}
+		if _, ok := byMessage[r4r.MessageID]; !ok {
+			messageIDs = append(messageIDs, r4r.MessageID)
+		}
+		byMessage[r4r.MessageID] = append(byMessage[r4r.MessageID], r4r)
+	}
+	if len(messageIDs) == 0 {
+		return "Sowwy, I couldn't find any react4roles messages in this server u_u"
+	}
+
+	roles, err := ds.GuildRoles(guildID)
+	if err != nil {
+		return "I don't have role management perms! >:("
+	}
+
+	migrated := 0
+	var errs []string
+	for _, id := range messageIDs {
+		if err := migrateReact4RolesMessage(ds, guildID, style, byMessage[id], roles); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", id, err.Error()))
+			continue
+		}
+		migrated++
+	}
+
+	result := fmt.Sprintf("Migrated %d react4roles messages", migrated)
+	if len(errs) > 0 {
+		result += "\nCouldn't migrate:\n" + strings.Join(errs, "\n")
+	}
+	return truncateString(result, discordMessageMaxLength)
+}
+
+func migrateReact4RolesMessage(ds *discordgo.Session, guildID, style string, r4rs []React4RoleMessage, roles []*discordgo.Role) error {
+	channelID, messageID := r4rs[0].ChannelID, r4rs[0].MessageID
+	entries := make([]RoleMenuEntry, 0, len(r4rs))
+	seen := map[string]bool{}
+	for _, r4r := range r4rs {
+		if seen[r4r.RoleID] {
+			continue
+		}
+		seen[r4r.RoleID] = true
+		entries = append(entries, RoleMenuEntry{
+			GuildID:        guildID,
+			ChannelID:      channelID,
+			MessageID:      messageID,
+			Style:          style,
+			RoleID:         r4r.RoleID,
+			RequiredRoleID: r4r.RequiredRoleID,
+			EmojiID:        r4r.EmojiID,
+			EmojiName:      r4r.EmojiName,
+			GroupMode:      r4r.GroupMode,
+			GroupLimit:     r4r.GroupLimit,
+		})
+	}
+	if len(entries) > roleMenuMaxRoles {
+		return fmt.Errorf("too many roles, the max is %d", roleMenuMaxRoles)
+	}
+
+	if err := moddingDS.addRoleMenuEntries(entries); err != nil {
+		moddingDS.deleteRoleMenu(messageID)
+		return err
+	}
+
+	content := buildRoleMenuMessage(roleMenuDefaultTitle, entries)
+	components := roleMenuComponents(style, entries, roles)
+	_, err := ds.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:              messageID,
+		Channel:         channelID,
+		Content:         &content,
+		Components:      &components,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		moddingDS.deleteRoleMenu(messageID)
+		return err
+	}
+
+	if err = moddingDS.deleteReact4Roles(channelID, messageID); err != nil {
+		return err
+	}
+	err = ds.MessageReactionsRemoveAll(channelID, messageID)
+	serverNotifyIfErr("migrateReact4RolesMessage::MessageReactionsRemoveAll", err, guildID, ds)
+	return nil
+}
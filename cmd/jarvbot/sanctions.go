@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Slash Command answers
+
+func answerKick(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	user := options["user"].UserValue(ds)
+	reason := options["reason"].StringValue()
+	actorID := interactionUser(ic).ID
+
+	if err := checkRoleHierarchy(ds, ic.GuildID, actorID, user.ID); err != nil {
+		textRespond(ds, ic, err.Error())
+		return
+	}
+	if _, err := guildMember(ds, ic.GuildID, user.ID); err != nil {
+		textRespond(ds, ic, "That user is not in the server :3")
+		return
+	}
+
+	dmSent := notifySanctionedUser(ds, ic.GuildID, user.ID, "kicked from", reason, 0)
+	err := ds.GuildMemberDeleteWithReason(ic.GuildID, user.ID, reason)
+	if err != nil {
+		if dmSent {
+			retractSanctionNotice(ds, ic.GuildID, user.ID, "kick")
+		}
+		textRespond(ds, ic, "Could not kick the user: "+err.Error())
+		return
+	}
+
+	caseNumber := recordSanction(ds, ModCase{
+		GuildID:  ic.GuildID,
+		ActorID:  actorID,
+		TargetID: user.ID,
+		Action:   caseActionKick,
+		Reason:   reason,
+		Source:   caseSourceCommand,
+	})
+	textRespond(ds, ic, sanctionResponse(user, "kicked", caseNumber, reason, dmSent))
+}
+
+func answerBan(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	banUser(ds, ic, 0)
+}
+
+func answerTempBan(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	duration := stringToDuration(options["duration"].StringValue())
+	if duration <= 0 {
+		textRespond(ds, ic, "Invalid duration value, use something like 7d or 12h")
+		return
+	}
+	banUser(ds, ic, duration)
+}
+
+func answerUnban(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	user := options["user"].UserValue(ds)
+	reason := ""
+	if opt, ok := options["reason"]; ok {
+		reason = opt.StringValue()
+	}
+
+	err := ds.GuildBanDelete(ic.GuildID, user.ID, discordgo.WithAuditLogReason(reason))
+	if err != nil {
+		textRespond(ds, ic, "Could not unban the user: "+err.Error())
+		return
+	}
+	removeScheduledUnbans(ic.GuildID, user.ID)
+
+	caseNumber := recordSanction(ds, ModCase{
+		GuildID:  ic.GuildID,
+		ActorID:  interactionUser(ic).ID,
+		TargetID: user.ID,
+		Action:   caseActionUnban,
+		Reason:   reason,
+		Source:   caseSourceCommand,
+	})
+	textRespond(ds, ic, sanctionResponse(user, "unbanned", caseNumber, reason, true))
+}
+
+func answerPurge(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	filter := purgeFilter{
+		count:           optionIntValueOrZero(options["count"]),
+		botsOnly:        optionBoolValueOrFalse(options["bots"]),
+		attachmentsOnly: optionBoolValueOrFalse(options["attachments"]),
+	}
+	if opt, ok := options["user"]; ok {
+		filter.userID = opt.UserValue(ds).ID
+	}
+	if opt, ok := options["contains"]; ok {
+		filter.contains = strings.ToLower(opt.StringValue())
+	}
+	reason := ""
+	if opt, ok := options["reason"]; ok {
+		reason = opt.StringValue()
+	}
+
+	// scanning and deleting can take longer than the 3 seconds Discord gives to answer
+	err := ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		serverNotifyIfErr("answerPurge::InteractionRespond", err, ic.GuildID, ds)
+		return
+	}
+
+	result := purgeResultMessage(ds, ic, filter, reason)
+	_, err = ds.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{Content: &result})
+	serverNotifyIfErr("answerPurge::InteractionResponseEdit", err, ic.GuildID, ds)
+}
+
+// Internal functions
+
+// banUser bans the user from the interaction's options, a positive duration makes the ban temporary
+func banUser(ds *discordgo.Session, ic *discordgo.InteractionCreate, duration time.Duration) {
+	options := optionMap(ic.ApplicationCommandData().Options)
+	user := options["user"].UserValue(ds)
+	reason := options["reason"].StringValue()
+	deleteDays := optionIntValueOrZero(options["delete_days"])
+	actorID := interactionUser(ic).ID
+
+	if err := checkRoleHierarchy(ds, ic.GuildID, actorID, user.ID); err != nil {
+		textRespond(ds, ic, err.Error())
+		return
+	}
+
+	dmSent := notifySanctionedUser(ds, ic.GuildID, user.ID, "banned from", reason, duration)
+	err := ds.GuildBanCreateWithReason(ic.GuildID, user.ID, reason, deleteDays)
+	if err != nil {
+		if dmSent {
+			retractSanctionNotice(ds, ic.GuildID, user.ID, "ban")
+		}
+		textRespond(ds, ic, "Could not ban the user: "+err.Error())
+		return
+	}
+
+	// a new ban replaces the previous one, including when it would have been lifted
+	removeScheduledUnbans(ic.GuildID, user.ID)
+	if duration > 0 {
+		err = schedulerDS.addScheduledActionAfterDuration(duration, user.ID, targetTypeUser, actionTypeUnban, ic.GuildID)
+		serverNotifyIfErr("banUser::addScheduledActionAfterDuration", err, ic.GuildID, ds)
+	}
+
+	caseNumber := recordSanction(ds, ModCase{
+		GuildID:         ic.GuildID,
+		ActorID:         actorID,
+		TargetID:        user.ID,
+		Action:          caseActionBan,
+		Reason:          reason,
+		DurationSeconds: int(duration.Seconds()),
+		Source:          caseSourceCommand,
+	})
+
+	action := "banned"
+	if duration > 0 {
+		action = "banned for " + humanDurationString(duration)
+	}
+	textRespond(ds, ic, sanctionResponse(user, action, caseNumber, reason, dmSent))
+}
+
+func removeScheduledUnbans(guildID, userID string) {
+	unbans, _ := schedulerDS.getScheduledActionsByTargetIDAndActionTypeAndActionData(userID, actionTypeUnban, guildID)
+	for _, a := range unbans {
+		schedulerDS.removeScheduledAction(a.ID)
+	}
+}
+
+// checkRoleHierarchy applies Discord's own rules: the actor and the bot must have a higher role than the target,
+// and nobody can act on the server owner. Targets that are not in the server can always be sanctioned
+func checkRoleHierarchy(ds *discordgo.Session, guildID, actorID, targetID string) error {
+	guild, err := ds.State.Guild(guildID)
+	if err != nil {
+		return errors.New("Couldn't get the server's roles :(")
+	}
+	if targetID == actorID {
+		return errors.New("You can't do that to yourself, silly :3")
+	}
+	if targetID == guild.OwnerID {
+		return errors.New("Nobody can do that to the server owner")
+	}
+	if targetID == ds.State.User.ID {
+		return errors.New("I'm not doing that to myself >:(")
+	}
+
+	target, err := guildMember(ds, guildID, targetID)
+	if isUnknownMemberErr(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("Couldn't get the target's roles: " + err.Error())
+	}
+	targetPosition := highestRolePosition(guild, target)
+
+	if actorID != guild.OwnerID {
+		actor, err := guildMember(ds, guildID, actorID)
+		if err != nil {
+			return errors.New("Couldn't get your roles: " + err.Error())
+		}
+		if highestRolePosition(guild, actor) <= targetPosition {
+			return errors.New("Your highest role must be above the target's highest role")
+		}
+	}
+
+	bot, err := guildMember(ds, guildID, ds.State.User.ID)
+	if err != nil {
+		return errors.New("Couldn't get my roles: " + err.Error())
+	}
+	if highestRolePosition(guild, bot) <= targetPosition {
+		return errors.New("My highest role must be above the target's highest role")
+	}
+	return nil
+}
+
+func highestRolePosition(guild *discordgo.Guild, member *discordgo.Member) int {
+	highest := 0
+	for _, roleID := range member.Roles {
+		if r := findRoleInSlice(roleID, guild.Roles); r != nil && r.Position > highest {
+			highest = r.Position
+		}
+	}
+	return highest
+}
+
+// notifySanctionedUser DMs the reason before the sanction is applied, since the bot can't DM users that share no server with it.
+// Returns false if the DM could not be sent
+func notifySanctionedUser(ds *discordgo.Session, guildID, userID, action, reason string, duration time.Duration) bool {
+	guildName := guildID
+	if g, err := ds.State.Guild(guildID); err == nil {
+		guildName = g.Name
+	}
+	msg := fmt.Sprintf("**You have been %s %s server**", action, guildName)
+	if duration > 0 {
+		msg += fmt.Sprintf(" until <t:%d>", time.Now().Add(duration).Unix())
+	}
+	msg += fmt.Sprintf(" for the following reason:\n*%s*", reason)
+	_, err := sendDirectMessage(userID, msg, ds)
+	return err == nil
+}
+
+// retractSanctionNotice tells the user that the sanction announced by notifySanctionedUser could not be applied
+func retractSanctionNotice(ds *discordgo.Session, guildID, userID, sanction string) {
+	guildName := guildID
+	if g, err := ds.State.Guild(guildID); err == nil {
+		guildName = g.Name
+	}
+	sendDirectMessage(userID, fmt.Sprintf("The %s from %s server could not be applied, please ignore the previous message", sanction, guildName), ds)
+}
+
+// recordSanction stores the case and sends it to the moderation logs. Returns the case number
+func recordSanction(ds *discordgo.Session, c ModCase) int {
+	c.CaseNumber = recordModCase(ds, c)
+	if c.CaseNumber != 0 {
+		c.CreatedAt = time.Now()
+		sendModLog(ds, c.GuildID, modCaseEmbed(c))
+	}
+	return c.CaseNumber
+}
+
+func sanctionResponse(user *discordgo.User, action string, caseNumber int, reason string, dmSent bool) string {
+	msg := fmt.Sprintf("The user %s has been %s (case #%d)", user.Username, action, caseNumber)
+	if reason != "" {
+		msg += fmt.Sprintf(". Reason: '%s'", reason)
+	}
+	if !dmSent {
+		msg += "\nCouldn't DM the reason to the user"
+	}
+	return msg
+}
+
+type purgeFilter struct {
+	count           int
+	userID          string
+	contains        string
+	botsOnly        bool
+	attachmentsOnly bool
+}
+
+func (f purgeFilter) matches(m *discordgo.Message) bool {
+	if m.Author == nil || m.Pinned {
+		return false
+	}
+	if f.userID != "" && m.Author.ID != f.userID {
+		return false
+	}
+	if f.botsOnly && !m.Author.Bot {
+		return false
+	}
+	if f.attachmentsOnly && len(m.Attachments) == 0 {
+		return false
+	}
+	return f.contains == "" || strings.Contains(strings.ToLower(m.Content), f.contains)
+}
+
+// purgeResultMessage deletes the newest messages of the channel that match the filter,
+// records a case for each affected author and returns the text to show to the mod
+func purgeResultMessage(ds *discordgo.Session, ic *discordgo.InteractionCreate, filter purgeFilter, reason string) string {
+	messages, err := purgeableMessages(ds, ic.ChannelID, filter)
+	if err != nil {
+		return "Could not read the channel's messages: " + err.Error()
+	}
+	if len(messages) == 0 {
+		return "No recent messages matched the filters :3"
+	}
+
+	messageIDs := make([]string, len(messages))
+	perAuthor := map[string]int{}
+	for i, m := range messages {
+		messageIDs[i] = m.ID
+		perAuthor[m.Author.ID]++
+	}
+	if len(messageIDs) == 1 {
+		err = ds.ChannelMessageDelete(ic.ChannelID, messageIDs[0])
+	} else {
+		err = ds.ChannelMessagesBulkDelete(ic.ChannelID, messageIDs)
+	}
+	if err != nil {
+		return "Could not delete the messages: " + err.Error()
+	}
+
+	actorID := interactionUser(ic).ID
+	for authorID, count := range perAuthor {
+		caseReason := fmt.Sprintf("Deleted %d messages in <#%s>", count, ic.ChannelID)
+		if reason != "" {
+			caseReason += ": " + reason
+		}
+		recordModCase(ds, ModCase{
+			GuildID:  ic.GuildID,
+			ActorID:  actorID,
+			TargetID: authorID,
+			Action:   caseActionPurge,
+			Reason:   caseReason,
+			Source:   caseSourceCommand,
+		})
+	}
+	if reason == "" {
+		reason = "No reason given"
+	}
+	sendModLog(ds, ic.GuildID, &discordgo.MessageEmbed{
+		Title:       "Messages purged",
+		Color:       colorRed,
+		Description: fmt.Sprintf("Mod: <@%s>\nChannel: <#%s>\nMessages: %d from %d users\nReason: %s", actorID, ic.ChannelID, len(messages), len(perAuthor), reason),
+	})
+	return fmt.Sprintf("Deleted %d messages!", len(messages))
+}
+
+// purgeableMessages scans the newest messages of the channel, skipping the ones too old to be bulk deleted
+func purgeableMessages(ds *discordgo.Session, channelID string, filter purgeFilter) ([]*discordgo.Message, error) {
+	var matched []*discordgo.Message
+	oldestAllowed := time.Now().Add(-bulkDeleteMaxMessageAge).Add(time.Minute)
+	beforeID := ""
+	for scanned := 0; scanned < purgeMaxScannedMessages; {
+		batch, err := ds.ChannelMessages(channelID, maxMessageCount, beforeID, "", "")
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range batch {
+			if m.Timestamp.Before(oldestAllowed) {
+				return matched, nil
+			}
+			if filter.matches(m) {
+				matched = append(matched, m)
+				if len(matched) == filter.count {
+					return matched, nil
+				}
+			}
+		}
+		if len(batch) < maxMessageCount {
+			break
+		}
+		scanned += len(batch)
+		beforeID = batch[len(batch)-1].ID
+	}
+	return matched, nil
+}
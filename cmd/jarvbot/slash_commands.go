@@ -9,6 +9,9 @@ import (
 )
 
 var moderatorMemberPermissions int64 = discordgo.PermissionBanMembers
+var kickMemberPermissions int64 = discordgo.PermissionKickMembers
+var manageMessagesPermissions int64 = discordgo.PermissionManageMessages
+var manageRolesPermissions int64 = discordgo.PermissionManageRoles
 
 var slashCommands = []*discordgo.ApplicationCommand{
 	{
@@ -273,12 +276,26 @@ var slashCommands = []*discordgo.ApplicationCommand{
 				Description: "If true, the bot will DM the warned user.",
 				Required:    true,
 			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "severity",
+				Description: "From 1 (minor) to 3 (severe), 1 by default",
+				Required:    false,
+				MinValue:    &warnSeverityMin,
+				MaxValue:    warnSeverityMax,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "expires_in",
+				Description: "When the warning expires, like 30d or 12h. Never by default",
+				Required:    false,
+			},
 		},
 	},
 	{
 		Name:                     "warnings",
 		DefaultMemberPermissions: &moderatorMemberPermissions,
-		Description:              "Check the warnings for a user (mods)",
+		Description:              "Check the warnings and mod notes of a user (mods)",
 		Options: []*discordgo.ApplicationCommandOption{
 			{
 				Type:        discordgo.ApplicationCommandOptionUser,
@@ -286,12 +303,552 @@ var slashCommands = []*discordgo.ApplicationCommand{
 				Description: "The user",
 				Required:    true,
 			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "filter",
+				Description: "Which warnings to show, active ones by default",
+				Required:    false,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Active", Value: warningFilterActive},
+					{Name: "Expired or pardoned", Value: warningFilterExpired},
+					{Name: "All", Value: warningFilterAll},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "page",
+				Description: "The page to show",
+				Required:    false,
+				MinValue:    &warningsPageMin,
+				MaxValue:    warningsPageMax,
+			},
+		},
+	},
+	{
+		Name:                     "warning",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Manage warnings (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "pardon",
+				Description: "Pardon a warning, it will be kept but won't count anymore",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The warning ID",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Remove a warning completely",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The warning ID",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "edit",
+				Description: "Edit the reason of a warning",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The warning ID",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "reason",
+						Description: "The new reason for the warning",
+						Required:    true,
+						MinLength:   &(warnMessageMinLength),
+						MaxLength:   warnMessageMaxLength,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "history",
+				Description: "Check who created or changed a warning",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The warning ID",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "export",
+				Description: "Export all the warnings of this server as CSV",
+			},
+		},
+	},
+	{
+		Name:                     "kick",
+		DefaultMemberPermissions: &kickMemberPermissions,
+		Description:              "Kick a user from the server (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user you want to kick",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "reason",
+				Description: "The reason for the kick, it will be sent to the user",
+				Required:    true,
+				MinLength:   &(warnMessageMinLength),
+				MaxLength:   warnMessageMaxLength,
+			},
+		},
+	},
+	{
+		Name:                     "ban",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Ban a user from the server (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user you want to ban",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "reason",
+				Description: "The reason for the ban, it will be sent to the user",
+				Required:    true,
+				MinLength:   &(warnMessageMinLength),
+				MaxLength:   warnMessageMaxLength,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "delete_days",
+				Description: "Delete the messages the user sent in the last days, from 0 to 7. None by default",
+				Required:    false,
+				MinValue:    &banDeleteDaysMin,
+				MaxValue:    banDeleteDaysMax,
+			},
+		},
+	},
+	{
+		Name:                     "tempban",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Ban a user from the server for some time (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user you want to ban",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "duration",
+				Description: "How long the ban lasts, like 7d or 12h",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "reason",
+				Description: "The reason for the ban, it will be sent to the user",
+				Required:    true,
+				MinLength:   &(warnMessageMinLength),
+				MaxLength:   warnMessageMaxLength,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "delete_days",
+				Description: "Delete the messages the user sent in the last days, from 0 to 7. None by default",
+				Required:    false,
+				MinValue:    &banDeleteDaysMin,
+				MaxValue:    banDeleteDaysMax,
+			},
+		},
+	},
+	{
+		Name:                     "unban",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Unban a user (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user you want to unban, you can paste their ID",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "reason",
+				Description: "The reason for the unban",
+				Required:    false,
+				MaxLength:   warnMessageMaxLength,
+			},
+		},
+	},
+	{
+		Name:                     "purge",
+		DefaultMemberPermissions: &manageMessagesPermissions,
+		Description:              "Delete the latest messages of this channel (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "count",
+				Description: "How many matching messages to delete, up to 100",
+				Required:    true,
+				MinValue:    &purgeCountMin,
+				MaxValue:    purgeCountMax,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only delete the messages of this user",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "contains",
+				Description: "Only delete the messages that contain this text",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "bots",
+				Description: "Only delete the messages sent by bots",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "attachments",
+				Description: "Only delete the messages with attachments",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "reason",
+				Description: "The reason for the purge",
+				Required:    false,
+				MaxLength:   warnMessageMaxLength,
+			},
+		},
+	},
+	{
+		Name:                     "note",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Manage the private mod notes of a user (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Add a note to a user, it won't count as a warning",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "The user",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "content",
+						Description: "The note",
+						Required:    true,
+						MinLength:   &(warnMessageMinLength),
+						MaxLength:   modNoteMaxLength,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List the notes of a user",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "The user",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Remove a note",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The note ID",
+						Required:    true,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:                     "case",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Check a moderation case (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "number",
+				Description: "The case number",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:                     "cases",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Check the moderation cases of a user (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "page",
+				Description: "The page to show",
+				Required:    false,
+				MinValue:    &warningsPageMin,
+				MaxValue:    warningsPageMax,
+			},
+		},
+	},
+	{
+		Name:                     "case_reason",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Amend the reason of a moderation case (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "number",
+				Description: "The case number",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "reason",
+				Description: "The new reason",
+				Required:    true,
+				MinLength:   &(warnMessageMinLength),
+				MaxLength:   warnMessageMaxLength,
+			},
+		},
+	},
+	{
+		Name:                     "automod",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Manage the automod rules (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Add an automod rule",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "match",
+						Description: "What the rule looks for",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Words (comma separated list)", Value: automodMatchWords},
+							{Name: "Regex", Value: automodMatchRegex},
+							{Name: "Discord invites", Value: automodMatchInvites},
+							{Name: "Domains (comma separated list)", Value: automodMatchDomains},
+							{Name: "Excessive caps", Value: automodMatchCaps},
+							{Name: "Excessive emoji", Value: automodMatchEmoji},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "action",
+						Description: "What to do with matching messages",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Log only", Value: automodActionLog},
+							{Name: "Delete and log", Value: automodActionDelete},
+							{Name: "Delete, warn and log", Value: automodActionWarn},
+							{Name: "Delete, timeout and log", Value: automodActionTimeout},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "pattern",
+						Description: "The words, regex or domains, depending on the match type",
+						Required:    false,
+						MaxLength:   automodPatternMaxLength,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "threshold",
+						Description: "Caps percentage (70 by default) or emoji count (10 by default)",
+						Required:    false,
+						MinValue:    &zero,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "duration",
+						Description: "How long the timeout lasts, like 10m or 1h. 10m by default",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "exempt_channels",
+						Description: "Channels or categories where the rule does not apply, mention them",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "exempt_roles",
+						Description: "Roles the rule does not apply to, mention them",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Remove an automod rule",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The rule ID",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List the automod rules of this server",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "test",
+				Description: "Check which rules a text would trigger, without doing anything",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "text",
+						Description: "The text to test",
+						Required:    true,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:                     "embed_command",
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+		Description:              "Create or edit a custom command that responds with an embed (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "key",
+				Description: "The command, like !mycommand",
+				Required:    true,
+				MaxLength:   commandKeyMaxLength,
+			},
+		},
+	},
+	{
+		Name:                     "rolemenu",
+		DefaultMemberPermissions: &manageRolesPermissions,
+		Description:              "Manage the button and select menu role menus (mods)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "create",
+				Description: "Post a role menu in this channel",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "style",
+						Description: "How the roles are picked",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Buttons", Value: roleMenuStyleButtons},
+							{Name: "Select menu", Value: roleMenuStyleSelect},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "mode",
+						Description: "How the roles of the menu interact with each other, any number of roles by default",
+						Required:    false,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Unique (one role at a time)", Value: roleGroupModeUnique},
+							{Name: "Limit (up to the limit option)", Value: roleGroupModeLimit},
+							{Name: "Verify (roles can't be removed)", Value: roleGroupModeVerify},
+							{Name: "Drop (roles can only be removed)", Value: roleGroupModeDrop},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "limit",
+						Description: "How many roles of the menu a member can have, for the limit mode",
+						Required:    false,
+						MinValue:    &roleGroupLimitMin,
+						MaxValue:    roleGroupLimitMax,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "migrate",
+				Description: "Turn react-for-roles messages into role menus",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "message_id",
+						Description: "The react-for-roles message, all the ones of this server if empty",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "style",
+						Description: "How the roles are picked, buttons by default",
+						Required:    false,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Buttons", Value: roleMenuStyleButtons},
+							{Name: "Select menu", Value: roleMenuStyleSelect},
+						},
+					},
+				},
+			},
 		},
 	},
 	{
 		Name: "Delete LinkFix Message",
 		Type: discordgo.MessageApplicationCommand,
 	},
+	{
+		Name:                     "Add mod note",
+		Type:                     discordgo.UserApplicationCommand,
+		DefaultMemberPermissions: &moderatorMemberPermissions,
+	},
 }
 
 var slashHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
@@ -304,7 +861,21 @@ var slashHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interacti
 	"character":              answerCharacter,
 	"warn":                   answerWarn,
 	"warnings":               answerWarnings,
+	"warning":                answerWarning,
+	"kick":                   answerKick,
+	"ban":                    answerBan,
+	"tempban":                answerTempBan,
+	"unban":                  answerUnban,
+	"purge":                  answerPurge,
+	"note":                   answerNote,
+	"case":                   answerCase,
+	"cases":                  answerCases,
+	"case_reason":            answerCaseReason,
+	"automod":                answerAutomod,
+	"embed_command":          answerEmbedCommand,
+	"rolemenu":               answerRoleMenu,
 	"Delete LinkFix Message": answerDeleteLinkFixMessage,
+	"Add mod note":           answerAddModNote,
 }
 
 func expensiveSlashCommand(expensiveOp func(ds *discordgo.Session, ic *discordgo.InteractionCreate)) func(ds *discordgo.Session, ic *discordgo.InteractionCreate) {
@@ -353,10 +924,20 @@ func textRespond(ds *discordgo.Session, ic *discordgo.InteractionCreate, textRes
 }
 
 func interactionFileRespond(ds *discordgo.Session, ic *discordgo.InteractionCreate, messageContent, fileName, fileData string) {
+	fileRespond(ds, ic, messageContent, fileName, fileData, 0)
+}
+
+// ephemeralFileRespond is interactionFileRespond for private data, only the user of the interaction sees the file
+func ephemeralFileRespond(ds *discordgo.Session, ic *discordgo.InteractionCreate, messageContent, fileName, fileData string) {
+	fileRespond(ds, ic, messageContent, fileName, fileData, discordgo.MessageFlagsEphemeral)
+}
+
+func fileRespond(ds *discordgo.Session, ic *discordgo.InteractionCreate, messageContent, fileName, fileData string, flags discordgo.MessageFlags) {
 	err := ds.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Content: messageContent,
+			Flags:   flags,
 			Files: []*discordgo.File{
 				{
 					ContentType: "text/plain",
@@ -13,6 +13,7 @@ var stringDaysRegex = regexp.MustCompile(`^(\d{1,2})d`)
 var stringHoursRegex = regexp.MustCompile(`^(\d{1,2})h`)
 var stringMinsRegex = regexp.MustCompile(`^(\d{1,2})m`)
 var stringSecsRegex = regexp.MustCompile(`^(\d{1,2})s`)
+var stringZeroDurationRegex = regexp.MustCompile(`^0+[dhms]?$`)
 
 const secondsInADay = 60 * 60 * 24
 
@@ -94,6 +95,13 @@ func stringToDuration(s string) time.Duration {
 	return result
 }
 
+// parseDurationFlag is stringToDuration for command flags, ok is false when the input is not a duration,
+// instead of silently reading it as zero. Only inputs like 0 or 0s mean zero
+func parseDurationFlag(s string) (d time.Duration, ok bool) {
+	d = stringToDuration(s)
+	return d, d > 0 || stringZeroDurationRegex.MatchString(strings.TrimSpace(s))
+}
+
 // From a string like "5m blablabla", uses the regexp provided (for example, stringMinsRegex)
 // to remove the duration part ("5m ")
 // and return the correct number of duration units (5)
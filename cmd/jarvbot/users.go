@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -22,33 +24,149 @@ func isAdmin(userID string) bool {
 	return userID == adminID
 }
 
-// isMod first checks if the user has the "Administrator" permission,
-// if not, it checks if they are a Mod for the server in the bot's DB
+type staffLevel int
+
+const (
+	staffLevelNone staffLevel = iota
+	staffLevelHelper
+	staffLevelMod
+)
+
+type staffCacheEntry struct {
+	level     staffLevel
+	expiresAt time.Time
+}
+
+type staffCacheKey struct {
+	guildID string
+	userID  string
+}
+
+var staffCache = map[staffCacheKey]staffCacheEntry{}
+var staffCacheMutex sync.Mutex
+
+// isMod is true for users with the "Administrator" permission, the server's mod list, or one of the server's mod roles
 func isMod(ds *discordgo.Session, userID, channelID string) bool {
+	return memberStaffLevel(ds, userID, channelID) >= staffLevelMod
+}
+
+// isHelper is true for mods and for members with one of the server's helper roles
+func isHelper(ds *discordgo.Session, userID, channelID string) bool {
+	return memberStaffLevel(ds, userID, channelID) >= staffLevelHelper
+}
+
+func memberStaffLevel(ds *discordgo.Session, userID, channelID string) staffLevel {
 	if userID == adminID {
-		return true
+		return staffLevelMod
 	}
 
-	channel, err := ds.Channel(channelID)
+	channel, err := ds.State.Channel(channelID)
+	if err != nil {
+		channel, err = ds.Channel(channelID)
+	}
 	if channel == nil || err != nil {
 		adminNotifyIfErr(fmt.Sprintf("ERROR isMod failed when retrieving channel %s\n", channelID), err, ds)
-		return false
+		return staffLevelNone
+	}
+	if channel.GuildID == "" {
+		return staffLevelNone
 	}
 
-	perms, err := ds.UserChannelPermissions(userID, channelID)
+	key := staffCacheKey{guildID: channel.GuildID, userID: userID}
+	staffCacheMutex.Lock()
+	entry, ok := staffCache[key]
+	if ok && time.Now().After(entry.expiresAt) {
+		delete(staffCache, key)
+		ok = false
+	}
+	staffCacheMutex.Unlock()
+	if ok {
+		return entry.level
+	}
+
+	level, err := resolveStaffLevel(ds, channel.GuildID, userID, channelID)
 	if err != nil {
-		adminNotifyIfErr(fmt.Sprintf("ERROR isMod failed for user %s in channel %s and guild %s\n", userID, channelID, channel.GuildID), err, ds)
 		serverNotifyIfErr(fmt.Sprintf("ERROR isMod failed for user %s in channel %s\n", userID, channelID), err, channel.GuildID, ds)
-	} else if perms&discordgo.PermissionAdministrator != 0 {
-		return true
+		return level
 	}
 
-	isMod, err := serverDS.ListPropertyContains(channel.GuildID, serverPropMods, userID, serverPropListSeparator)
+	staffCacheMutex.Lock()
+	staffCache[key] = staffCacheEntry{level: level, expiresAt: time.Now().Add(staffCacheTTL)}
+	staffCacheMutex.Unlock()
+	return level
+}
+
+func resolveStaffLevel(ds *discordgo.Session, guildID, userID, channelID string) (staffLevel, error) {
+	listed, err := serverDS.ListPropertyContains(guildID, serverPropMods, userID, serverPropListSeparator)
 	if err != nil {
-		serverNotifyIfErr("Could not check if that user is a mod.", err, channel.GuildID, ds)
-		return false
+		return staffLevelNone, err
+	}
+	if listed {
+		return staffLevelMod, nil
+	}
+
+	member, err := guildMember(ds, guildID, userID)
+	if isUnknownMemberErr(err) {
+		return staffLevelNone, nil
+	}
+	if err != nil {
+		return staffLevelNone, err
+	}
+
+	perms, err := ds.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return staffLevelNone, err
+	}
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return staffLevelMod, nil
+	}
+
+	modRoles, err := serverDS.GetListProperty(guildID, serverPropModRoles, serverPropListSeparator)
+	if err != nil {
+		return staffLevelNone, err
+	}
+	if isMemberInAnyRole(member, modRoles) {
+		return staffLevelMod, nil
+	}
+
+	helperRoles, err := serverDS.GetListProperty(guildID, serverPropHelperRoles, serverPropListSeparator)
+	if err != nil {
+		return staffLevelNone, err
+	}
+	if isMemberInAnyRole(member, helperRoles) {
+		return staffLevelHelper, nil
+	}
+	return staffLevelNone, nil
+}
+
+func invalidateStaffCache(guildID, userID string) {
+	staffCacheMutex.Lock()
+	defer staffCacheMutex.Unlock()
+	delete(staffCache, staffCacheKey{guildID: guildID, userID: userID})
+}
+
+// invalidateGuildStaffCache is needed when a role changes or the staff configuration of the guild changes
+func invalidateGuildStaffCache(guildID string) {
+	staffCacheMutex.Lock()
+	defer staffCacheMutex.Unlock()
+	for key := range staffCache {
+		if key.guildID == guildID {
+			delete(staffCache, key)
+		}
+	}
+}
+
+// users that are not checked again keep their entry after it expires, so they are removed here
+func pruneStaffCacheCRONFunc(ds *discordgo.Session) func() {
+	return func() {
+		staffCacheMutex.Lock()
+		defer staffCacheMutex.Unlock()
+		for key, entry := range staffCache {
+			if time.Now().After(entry.expiresAt) {
+				delete(staffCache, key)
+			}
+		}
 	}
-	return isMod
 }
 
 var userChannels = map[string]*discordgo.Channel{}
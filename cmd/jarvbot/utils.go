@@ -86,6 +86,20 @@ func formatInColumns(items []string, columns int, leftToRight bool) string {
 	return builder.String()
 }
 
+var discordIDRegex = regexp.MustCompile(`\d{15,21}`)
+
+// extractDiscordIDs finds all the snowflakes in a string, so mentions like <#123> or <@&123> also work
+func extractDiscordIDs(s string) []string {
+	return discordIDRegex.FindAllString(s, -1)
+}
+
+func splitNonEmpty(s, sep string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, sep)
+}
+
 func truncateString(s string, n int) string {
 	if len(s) > n {
 		return s[:n] + "…"
@@ -155,6 +169,38 @@ func cleanMessageContent(content string) string {
 	return urlRegex.ReplaceAllStringFunc(content, sanitizeURL)
 }
 
+// levenshteinDistance counts the single rune insertions, deletions and substitutions needed to turn a into b
+func levenshteinDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// replaceMemberPlaceholders fills the placeholders shared by the mine and welcome messages
+func replaceMemberPlaceholders(message string, user *discordgo.User, joinedAt time.Time) string {
+	return strings.NewReplacer(
+		"<user>", user.Mention(),
+		"<username>", user.Username,
+		"<joinyear>", joinedAt.Format("2006"),
+		"<curryear>", time.Now().Format("2006"),
+	).Replace(message)
+}
+
 // ==================== MATH ====================
 
 func divideToFloat(a, b int) float64 {
@@ -179,6 +225,14 @@ func findRoleInSlice(roleID string, roles []*discordgo.Role) *discordgo.Role {
 	return nil
 }
 
+func guildHasRole(ds *discordgo.Session, guildID, roleID string) bool {
+	if _, err := ds.State.Role(guildID, roleID); err == nil {
+		return true
+	}
+	roles, err := ds.GuildRoles(guildID)
+	return err == nil && findRoleInSlice(roleID, roles) != nil
+}
+
 func guildRoleByName(ds *discordgo.Session, guildID string, roleName string) (*discordgo.Role, error) {
 	roles, err := ds.GuildRoles(guildID)
 	if err != nil {
@@ -192,6 +246,26 @@ func guildRoleByName(ds *discordgo.Session, guildID string, roleName string) (*d
 	return nil, fmt.Errorf("role with name %s not found in guild with id %s", roleName, guildID)
 }
 
+// isUnknownMemberErr is true when the user is not in the guild anymore
+func isUnknownMemberErr(err error) bool {
+	restErr, ok := err.(*discordgo.RESTError)
+	return ok && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMember
+}
+
+// guildMember looks for the member in the state before asking Discord
+func guildMember(ds *discordgo.Session, guildID, userID string) (*discordgo.Member, error) {
+	member, err := ds.State.Member(guildID, userID)
+	if err != nil {
+		member, err = ds.GuildMember(guildID, userID)
+	}
+	return member, err
+}
+
+func isGuildMember(ds *discordgo.Session, guildID, userID string) bool {
+	_, err := guildMember(ds, guildID, userID)
+	return err == nil
+}
+
 func isMemberInRole(member *discordgo.Member, roleID string) bool {
 	for _, r := range member.Roles {
 		if r == roleID {
@@ -201,6 +275,15 @@ func isMemberInRole(member *discordgo.Member, roleID string) bool {
 	return false
 }
 
+func isMemberInAnyRole(member *discordgo.Member, roleIDs []string) bool {
+	for _, roleID := range roleIDs {
+		if isMemberInRole(member, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
 // ==================== CHANNELS ====================
 
 func channelBelongsToGuild(ds *discordgo.Session, channelID, guildID string) bool {
@@ -218,6 +301,26 @@ func channelBelongsToGuild(ds *discordgo.Session, channelID, guildID string) boo
 	return channel.GuildID == guildID
 }
 
+func isUnknownChannelErr(err error) bool {
+	restErr, ok := err.(*discordgo.RESTError)
+	return ok && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownChannel
+}
+
+// channelAndParentIDs returns the channel ID followed by its parents found in the state,
+// the parent of a thread is a channel, and the parent of a channel is a category
+func channelAndParentIDs(ds *discordgo.Session, channelID string) []string {
+	ids := []string{channelID}
+	for range 2 {
+		channel, err := ds.State.Channel(channelID)
+		if err != nil || channel.ParentID == "" {
+			break
+		}
+		channelID = channel.ParentID
+		ids = append(ids, channelID)
+	}
+	return ids
+}
+
 // ==================== IMAGES ====================
 
 func GenerateQRImage(data string, border int) ([]byte, error) {
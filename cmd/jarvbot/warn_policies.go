@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type addWarnPolicyInput struct {
+	Count    int    `short:"c" long:"count" required:"true" description:"Amount of warnings that trigger the sanction"`
+	Window   string `short:"w" long:"window" default:"30d" description:"Only warnings newer than this count, format: 99d99h99m"`
+	Action   string `short:"a" long:"action" required:"true" choice:"timeout" choice:"kick" choice:"ban" description:"The sanction"`
+	Duration string `short:"d" long:"duration" default:"" description:"How long the timeout role or the ban will last, format: 99d99h99m. Empty for permanent bans"`
+}
+
+// Command Answers
+
+func answerAddWarnPolicy(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var input addWarnPolicyInput
+	if err := parseCommandArgs(&input, mc.Content); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+
+	if input.Count <= 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "The warning count must be greater than 0")
+		return false
+	}
+	window, ok := parseDurationFlag(input.Window)
+	if !ok || window <= 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "The window must be a duration like 30d")
+		return false
+	}
+	var duration time.Duration
+	if input.Duration != "" {
+		// only an empty duration means permanent, so a typo can't turn into a permanent ban
+		duration, ok = parseDurationFlag(input.Duration)
+		if !ok || duration <= 0 {
+			ds.ChannelMessageSend(mc.ChannelID, "The duration must be a duration like 7d, leave it empty for permanent bans")
+			return false
+		}
+	}
+
+	policy := WarnPolicy{
+		GuildID:         mc.GuildID,
+		WarnCount:       input.Count,
+		WindowSeconds:   int(window.Seconds()),
+		Action:          input.Action,
+		DurationSeconds: int(duration.Seconds()),
+	}
+	if policy.Action == warnPolicyActionTimeout && policy.DurationSeconds <= 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "Timeouts need a duration, like -d 1h")
+		return false
+	}
+
+	current, err := moddingDS.warnPolicies(mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerAddWarnPolicy::warnPolicies", err, mc.GuildID, ds)
+		return false
+	}
+	if len(current) >= warnPoliciesMaxPerGuild {
+		ds.ChannelMessageSend(mc.ChannelID, "Too many warning policies!, please clean up before adding more :3")
+		return false
+	}
+
+	err = moddingDS.addWarnPolicy(policy)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Could not add the warning policy: "+err.Error())
+		return false
+	}
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+func answerWarnPolicies(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	policies, err := moddingDS.warnPolicies(mc.GuildID)
+	if err != nil {
+		serverNotifyIfErr("answerWarnPolicies", err, mc.GuildID, ds)
+		return false
+	}
+	if len(policies) == 0 {
+		ds.ChannelMessageSend(mc.ChannelID, "No warning policies, wanna add some? :3 (!addwarnpolicy)")
+		return true
+	}
+
+	var b strings.Builder
+	for i := len(policies) - 1; i >= 0; i-- {
+		b.WriteString(fmt.Sprintf("**ID %d**: %s\n", policies[i].ID, policies[i]))
+	}
+	_, err = ds.ChannelMessageSendEmbed(mc.ChannelID, &discordgo.MessageEmbed{
+		Title:       "Warning policies",
+		Color:       colorYellow,
+		Description: b.String(),
+	})
+	return err == nil
+}
+
+func answerRemoveWarnPolicy(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	fields := strings.Fields(mc.Content)
+	if len(fields) == 1 {
+		return false
+	}
+
+	policyID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Policy ID was not a number! :<")
+		return false
+	}
+
+	err = moddingDS.removeWarnPolicy(policyID, mc.GuildID)
+	if err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, "Error: "+err.Error())
+		return false
+	}
+	ds.ChannelMessageSend(mc.ChannelID, commandSuccessMessage)
+	return true
+}
+
+// Internal functions
+
+func (p WarnPolicy) String() string {
+	sanction := p.Action
+	if p.DurationSeconds > 0 {
+		sanction += " for " + humanDurationString(p.Duration())
+	}
+	return fmt.Sprintf("%d warnings in %s → %s", p.WarnCount, humanDurationString(p.Window()), sanction)
+}
+
+// applyWarnPolicies is called after each warning, it applies the most severe policy the user has just reached
+// A policy only triggers on the warning that reaches its count, so later warnings don't sanction again
+// Returns the applied policy, or nil if none was applied
+func applyWarnPolicies(ds *discordgo.Session, guildID, userID string) (*WarnPolicy, error) {
+	policies, err := moddingDS.warnPolicies(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, policy := range policies {
+		count, err := moddingDS.countUserWarningsSince(userID, guildID, time.Now().Add(-policy.Window()))
+		if err != nil {
+			return nil, err
+		}
+		if count != policy.WarnCount {
+			continue
+		}
+
+		reason := fmt.Sprintf("Automatic sanction: %d warnings in %s", count, humanDurationString(policy.Window()))
+		caseNumber, err := applyWarnPolicy(ds, guildID, userID, policy, reason)
+		if err != nil {
+			return nil, err
+		}
+		sendModLog(ds, guildID, &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("Automatic sanction (case #%d)", caseNumber),
+			Color:       colorRed,
+			Description: fmt.Sprintf("User: <@%s>\nSanction: %s\nPolicy: %s", userID, policy.Action, policy),
+		})
+		return &policy, nil
+	}
+	return nil, nil
+}
+
+// applyWarnPolicy applies the sanction and returns its case number
+func applyWarnPolicy(ds *discordgo.Session, guildID, userID string, policy WarnPolicy, reason string) (int, error) {
+	modCase := ModCase{
+		GuildID:         guildID,
+		ActorID:         ds.State.User.ID,
+		TargetID:        userID,
+		Reason:          reason,
+		DurationSeconds: policy.DurationSeconds,
+		Source:          caseSourceWarnPolicy,
+	}
+
+	switch policy.Action {
+	case warnPolicyActionTimeout:
+		timeoutRoleID, err := resolveTimeoutRoleID(ds, guildID)
+		if err != nil {
+			return 0, err
+		}
+		return sendToShadowRealm(ds, guildID, userID, timeoutRoleID, policy.Duration(), modCase.ActorID, modCase.Source, reason)
+	case warnPolicyActionKick:
+		err := ds.GuildMemberDeleteWithReason(guildID, userID, reason)
+		if err != nil {
+			return 0, err
+		}
+		modCase.Action = caseActionKick
+	case warnPolicyActionBan:
+		err := ds.GuildBanCreateWithReason(guildID, userID, reason, 0)
+		if err != nil {
+			return 0, err
+		}
+		if policy.DurationSeconds > 0 {
+			err = schedulerDS.addScheduledActionAfterDuration(policy.Duration(), userID, targetTypeUser, actionTypeUnban, guildID)
+			serverNotifyIfErr("applyWarnPolicy::addScheduledActionAfterDuration", err, guildID, ds)
+		}
+		modCase.Action = caseActionBan
+	default:
+		return 0, fmt.Errorf("unknown warning policy action: %s", policy.Action)
+	}
+	return recordModCase(ds, modCase), nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type welcomeInput struct {
+	Here    bool   `long:"here" description:"Send the welcome and goodbye messages to this channel"`
+	Channel string `short:"c" long:"channel" description:"Send the welcome and goodbye messages to this channel. 'none' only sends them by DM"`
+	Message string `short:"m" long:"message" description:"The welcome message, placeholders: <user> <username> <server> <membercount> <joinyear> <curryear>. 'none' disables it"`
+	Goodbye string `short:"g" long:"goodbye" description:"The goodbye message, same placeholders as the welcome message. 'none' disables it"`
+	DM      string `long:"dm" choice:"on" choice:"off" description:"If on, the welcome message is also sent to the new member by DM"`
+}
+
+type autoRolesInput struct {
+	Roles          string `short:"r" long:"roles" description:"Roles given to new members, separated by spaces or commas. 'none' removes them"`
+	Delay          string `short:"d" long:"delay" description:"The roles are given after this delay, format: 99d99h99m, up to 7d. 0s gives them right away"`
+	MinAge         string `short:"a" long:"min-age" description:"Accounts younger than this are quarantined instead, format: 99d99h. 0s disables the gate"`
+	QuarantineRole string `short:"q" long:"quarantine-role" description:"The role given to the quarantined accounts"`
+}
+
+// Command Answers
+
+// Format: !welcome [flags]
+// Without flags, it shows the current settings
+func answerWelcome(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var input welcomeInput
+	if err := parseCommandArgs(&input, mc.Content); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+
+	properties := map[string]string{}
+	if input.Here {
+		properties[serverPropWelcomeChannel] = mc.ChannelID
+	}
+	if input.Channel != "" {
+		channelIDs := extractDiscordIDs(input.Channel)
+		switch {
+		case strings.EqualFold(input.Channel, "none"):
+			properties[serverPropWelcomeChannel] = ""
+		case len(channelIDs) != 1 || !channelBelongsToGuild(ds, channelIDs[0], mc.GuildID):
+			ds.ChannelMessageSend(mc.ChannelID, "Please mention a single channel of this server")
+			return false
+		default:
+			properties[serverPropWelcomeChannel] = channelIDs[0]
+		}
+	}
+	for prop, message := range map[string]string{serverPropWelcomeMessage: input.Message, serverPropGoodbyeMessage: input.Goodbye} {
+		if message == "" {
+			continue
+		}
+		if len(message) > welcomeMessageMaxLength {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The messages can't be longer than %d characters", welcomeMessageMaxLength))
+			return false
+		}
+		if strings.EqualFold(message, "none") {
+			message = ""
+		}
+		properties[prop] = message
+	}
+	if input.DM != "" {
+		properties[serverPropWelcomeDM] = map[string]string{"on": serverPropYes, "off": serverPropNo}[input.DM]
+	}
+
+	for name, value := range properties {
+		if err := serverDS.setServerProperty(mc.GuildID, name, value); err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Could not save the welcome settings: "+err.Error())
+			return false
+		}
+	}
+
+	_, err := ds.ChannelMessageSendEmbed(mc.ChannelID, welcomeSettingsEmbed(mc.GuildID))
+	return err == nil
+}
+
+// Format: !autoroles [flags]
+// Without flags, it shows the current settings
+func answerAutoRoles(ds *discordgo.Session, mc *discordgo.MessageCreate, ctx context.Context) bool {
+	var input autoRolesInput
+	if err := parseCommandArgs(&input, mc.Content); err != nil {
+		ds.ChannelMessageSend(mc.ChannelID, err.Error())
+		return false
+	}
+
+	properties := map[string]string{}
+	if input.Roles != "" {
+		roleIDs := extractDiscordIDs(input.Roles)
+		if len(roleIDs) > autoRolesMax {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Too many roles!, the max is %d", autoRolesMax))
+			return false
+		}
+		for _, id := range roleIDs {
+			if id == mc.GuildID || !guildHasRole(ds, mc.GuildID, id) {
+				ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("Could not find the role %s in this server", id))
+				return false
+			}
+		}
+		properties[serverPropAutoRoles] = strings.Join(roleIDs, serverPropListSeparator)
+	}
+	if input.Delay != "" {
+		delay, ok := parseDurationFlag(input.Delay)
+		if !ok || delay > autoRoleMaxDelay {
+			ds.ChannelMessageSend(mc.ChannelID, fmt.Sprintf("The delay must be a duration like 10m, up to %s", humanDurationString(autoRoleMaxDelay)))
+			return false
+		}
+		properties[serverPropAutoRoleDelay] = strconv.Itoa(int(delay.Seconds()))
+	}
+	if input.MinAge != "" {
+		minAge, ok := parseDurationFlag(input.MinAge)
+		if !ok {
+			ds.ChannelMessageSend(mc.ChannelID, "The minimum age must be a duration like 7d, 0s disables the gate")
+			return false
+		}
+		properties[serverPropMinAccountAge] = strconv.Itoa(int(minAge.Seconds()))
+	}
+	if input.QuarantineRole != "" {
+		roleIDs := extractDiscordIDs(input.QuarantineRole)
+		if len(roleIDs) != 1 || roleIDs[0] == mc.GuildID || !guildHasRole(ds, mc.GuildID, roleIDs[0]) {
+			ds.ChannelMessageSend(mc.ChannelID, "Please mention a single role of this server as the quarantine role")
+			return false
+		}
+		properties[serverPropQuarantineRole] = roleIDs[0]
+	}
+
+	for name, value := range properties {
+		if err := serverDS.setServerProperty(mc.GuildID, name, value); err != nil {
+			ds.ChannelMessageSend(mc.ChannelID, "Could not save the auto-role settings: "+err.Error())
+			return false
+		}
+	}
+
+	_, err := ds.ChannelMessageSendEmbed(mc.ChannelID, autoRolesSettingsEmbed(mc.GuildID))
+	return err == nil
+}
+
+func welcomeSettingsEmbed(guildID string) *discordgo.MessageEmbed {
+	channel := "None"
+	if channelID, _ := serverDS.getServerProperty(guildID, serverPropWelcomeChannel); channelID != "" {
+		channel = "<#" + channelID + ">"
+	}
+	dm := "No"
+	if welcomeDM, _ := serverDS.getServerProperty(guildID, serverPropWelcomeDM); welcomeDM == serverPropYes {
+		dm = "Yes"
+	}
+	welcome, _ := serverDS.getServerProperty(guildID, serverPropWelcomeMessage)
+	goodbye, _ := serverDS.getServerProperty(guildID, serverPropGoodbyeMessage)
+
+	return &discordgo.MessageEmbed{
+		Title: "Welcome settings",
+		Color: colorBlue,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Channel", Value: channel, Inline: true},
+			{Name: "Welcome by DM", Value: dm, Inline: true},
+			{Name: "Welcome message", Value: truncateString(valueOrDisabled(welcome), embedFieldValueMaxLength-1)},
+			{Name: "Goodbye message", Value: truncateString(valueOrDisabled(goodbye), embedFieldValueMaxLength-1)},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "Change them with !welcome [flags], see !welcome --help"},
+	}
+}
+
+func autoRolesSettingsEmbed(guildID string) *discordgo.MessageEmbed {
+	roles := "None"
+	if roleIDs, _ := serverDS.GetListProperty(guildID, serverPropAutoRoles, serverPropListSeparator); len(roleIDs) > 0 {
+		roles = "<@&" + strings.Join(roleIDs, "> <@&") + ">"
+	}
+	delay := "None"
+	if d := autoRoleDelay(guildID); d > 0 {
+		delay = humanDurationString(d)
+	}
+	gate := "Disabled"
+	if minAge := minAccountAge(guildID); minAge > 0 {
+		gate = "Accounts younger than " + humanDurationString(minAge)
+		if roleID, _ := serverDS.getServerProperty(guildID, serverPropQuarantineRole); roleID != "" {
+			gate += " get <@&" + roleID + "> instead of the roles"
+		} else {
+			gate += " don't get the roles, configure a quarantine role with -q"
+		}
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "Auto-role settings",
+		Color: colorBlue,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Roles", Value: truncateString(roles, embedFieldValueMaxLength-1), Inline: true},
+			{Name: "Delay", Value: delay, Inline: true},
+			{Name: "Account age gate", Value: gate},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "Change them with !autoroles [flags], see !autoroles --help"},
+	}
+}
+
+// Internal functions
+
+// welcomeMember gates very new accounts, gives the auto-roles and sends the welcome message
+func welcomeMember(ds *discordgo.Session, guildID string, member *discordgo.Member) {
+	if quarantineNewAccount(ds, guildID, member.User.ID) {
+		return
+	}
+	giveAutoRoles(ds, guildID, member.User.ID)
+
+	message, _ := serverDS.getServerProperty(guildID, serverPropWelcomeMessage)
+	if message == "" {
+		return
+	}
+	message = buildMemberMessage(ds, guildID, message, member.User, member.JoinedAt)
+
+	if channelID, _ := serverDS.getServerProperty(guildID, serverPropWelcomeChannel); channelID != "" {
+		_, err := ds.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:         message,
+			AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{member.User.ID}},
+		})
+		serverNotifyIfErr("welcomeMember::ChannelMessageSendComplex", err, guildID, ds)
+	}
+	if welcomeDM, _ := serverDS.getServerProperty(guildID, serverPropWelcomeDM); welcomeDM == serverPropYes {
+		// many users don't accept DMs from server members, it's not worth notifying
+		sendDirectMessage(member.User.ID, message, ds)
+	}
+}
+
+func sendGoodbyeMessage(ds *discordgo.Session, guildID string, member *discordgo.Member) {
+	message, _ := serverDS.getServerProperty(guildID, serverPropGoodbyeMessage)
+	channelID, _ := serverDS.getServerProperty(guildID, serverPropWelcomeChannel)
+	if message == "" || channelID == "" {
+		return
+	}
+	_, err := ds.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         buildMemberMessage(ds, guildID, message, member.User, member.JoinedAt),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	serverNotifyIfErr("sendGoodbyeMessage", err, guildID, ds)
+}
+
+func buildMemberMessage(ds *discordgo.Session, guildID, message string, user *discordgo.User, joinedAt time.Time) string {
+	if joinedAt.IsZero() {
+		joinedAt = time.Now()
+	}
+	message = replaceMemberPlaceholders(message, user, joinedAt)
+	if g, err := ds.State.Guild(guildID); err == nil {
+		message = strings.NewReplacer("<server>", g.Name, "<membercount>", strconv.Itoa(g.MemberCount)).Replace(message)
+	}
+	return message
+}
+
+// quarantineNewAccount gives the quarantine role to accounts younger than the guild's minimum age.
+// Returns true if the account is too young, in which case it must not get the auto-roles
+func quarantineNewAccount(ds *discordgo.Session, guildID, userID string) bool {
+	minAge := minAccountAge(guildID)
+	createdAt, err := discordgo.SnowflakeTimestamp(userID)
+	if minAge <= 0 || err != nil || time.Since(createdAt) >= minAge {
+		return false
+	}
+
+	roleID, _ := serverDS.getServerProperty(guildID, serverPropQuarantineRole)
+	if roleID == "" {
+		return true
+	}
+	err = ds.GuildMemberRoleAdd(guildID, userID, roleID)
+	if err != nil {
+		serverNotifyIfErr(fmt.Sprintf("Couldn't quarantine the new account <@%s>", userID), err, guildID, ds)
+		return true
+	}
+
+	reason := fmt.Sprintf("Account created <t:%d:R>, the minimum age is %s", createdAt.Unix(), humanDurationString(minAge))
+	caseNumber := recordModCase(ds, ModCase{
+		GuildID:  guildID,
+		ActorID:  ds.State.User.ID,
+		TargetID: userID,
+		Action:   caseActionQuarantine,
+		Reason:   reason,
+		Source:   caseSourceAccountAge,
+	})
+	sendModLog(ds, guildID, &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("New account quarantined (case #%d)", caseNumber),
+		Color:       colorYellow,
+		Description: fmt.Sprintf("User: <@%s>\nRole: <@&%s>\n%s", userID, roleID, reason),
+	})
+	return true
+}
+
+// giveAutoRoles gives the auto-roles now, or schedules them if the guild has a delay
+func giveAutoRoles(ds *discordgo.Session, guildID, userID string) {
+	roleIDs, err := serverDS.GetListProperty(guildID, serverPropAutoRoles, serverPropListSeparator)
+	if err != nil || len(roleIDs) == 0 {
+		serverNotifyIfErr("giveAutoRoles::GetListProperty", err, guildID, ds)
+		return
+	}
+
+	delay := autoRoleDelay(guildID)
+	for _, roleID := range roleIDs {
+		if delay > 0 {
+			err = schedulerDS.addScheduledActionAfterDuration(delay, userID, targetTypeUser, actionTypeAddRole, guildID+";"+roleID)
+		} else {
+			err = ds.GuildMemberRoleAdd(guildID, userID, roleID)
+		}
+		serverNotifyIfErr(fmt.Sprintf("Couldn't give the auto-role <@&%s> to <@%s>", roleID, userID), err, guildID, ds)
+	}
+}
+
+func autoRoleDelay(guildID string) time.Duration {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropAutoRoleDelay)
+	seconds, _ := strconv.Atoi(raw)
+	return time.Duration(seconds) * time.Second
+}
+
+func minAccountAge(guildID string) time.Duration {
+	raw, _ := serverDS.getServerProperty(guildID, serverPropMinAccountAge)
+	seconds, _ := strconv.Atoi(raw)
+	return time.Duration(seconds) * time.Second
+}
+
+func valueOrDisabled(value string) string {
+	if value == "" {
+		return "Disabled"
+	}
+	return value
+}